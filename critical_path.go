@@ -0,0 +1,171 @@
+package asyncjob
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Azure/go-asyncjob/graph"
+)
+
+// CriticalPath is the chain of steps that determined the wall time of a finished job instance.
+type CriticalPath struct {
+	// Steps on the critical path, from root step to the step finished last.
+	Steps []*CriticalPathStep
+	// Duration from job instance start to the last step finished.
+	Duration time.Duration
+	// Slack of every step by name: how long the step could be delayed without delaying the job, zero for steps on the critical path.
+	Slack map[string]time.Duration
+}
+
+// CriticalPathStep is a step on the critical path.
+type CriticalPathStep struct {
+	Name      string
+	StartTime time.Time
+	Duration  time.Duration
+	// Wait between the last dependency finished and this step started, like scheduling delay or context enrichment.
+	Wait time.Duration
+}
+
+// CriticalPath of a finished job instance, computed from StartTime and Duration of steps.
+//
+//	steps not executed (restored from StateStore or skipped) take no time, they finish as soon as all dependencies finished.
+//	returns ErrJobInstanceNotFinished if the job instance is not finished yet.
+func (ji *JobInstance[T]) CriticalPath() (*CriticalPath, error) {
+	state := ji.GetState()
+	if !state.IsTerminal() {
+		return nil, ErrJobInstanceNotFinished.WithMessage(fmt.Sprintf(MsgJobInstanceNotFinished, ji.GetJobInstanceId(), state))
+	}
+
+	jobStartTime := ji.StartTime()
+	steps := make(map[string]*StepInstanceSnapshot)
+	for _, step := range ji.getSteps() {
+		steps[step.GetName()] = snapshotStep(step)
+	}
+
+	// earliest finish of each step, in topological order so dependencies are always computed first.
+	ordered := make([]*StepInstanceSnapshot, 0, len(steps))
+	startTimes := make(map[string]time.Time, len(steps))
+	finishTimes := make(map[string]time.Time, len(steps))
+	var last *StepInstanceSnapshot
+	for _, stepDef := range ji.Definition.stepsDag.TopologicalSort() {
+		step, ok := steps[stepDef.GetName()]
+		if !ok {
+			continue
+		}
+		ordered = append(ordered, step)
+
+		dependenciesFinished := jobStartTime
+		for _, dependency := range step.DependsOn {
+			if finishTime := finishTimes[dependency]; finishTime.After(dependenciesFinished) {
+				dependenciesFinished = finishTime
+			}
+		}
+		startTimes[step.Name] = dependenciesFinished
+		finishTimes[step.Name] = dependenciesFinished
+		if step.StartTime != nil && !step.Restored {
+			startTimes[step.Name] = *step.StartTime
+			finishTimes[step.Name] = step.StartTime.Add(step.Duration)
+		}
+
+		if last == nil || !finishTimes[step.Name].Before(finishTimes[last.Name]) {
+			last = step
+		}
+	}
+
+	criticalPath := &CriticalPath{Slack: make(map[string]time.Duration, len(ordered))}
+	if last == nil {
+		return criticalPath, nil
+	}
+	jobFinishTime := finishTimes[last.Name]
+	criticalPath.Duration = jobFinishTime.Sub(jobStartTime)
+
+	// walk back from the step finished last, through the dependency it waited for.
+	for step := last; step != nil; {
+		var waitedFor *StepInstanceSnapshot
+		for _, dependency := range step.DependsOn {
+			if dependencyStep, ok := steps[dependency]; ok && (waitedFor == nil || finishTimes[dependency].After(finishTimes[waitedFor.Name])) {
+				waitedFor = dependencyStep
+			}
+		}
+
+		pathStep := &CriticalPathStep{
+			Name:      step.Name,
+			StartTime: startTimes[step.Name],
+			Duration:  finishTimes[step.Name].Sub(startTimes[step.Name]),
+		}
+		if waitedFor != nil {
+			pathStep.Wait = max(0, startTimes[step.Name].Sub(finishTimes[waitedFor.Name]))
+		}
+		criticalPath.Steps = append([]*CriticalPathStep{pathStep}, criticalPath.Steps...)
+		step = waitedFor
+	}
+
+	// latest finish of each step without delaying the job, in reverse topological order so dependents are always computed first.
+	latestFinishTimes := make(map[string]time.Time, len(ordered))
+	for _, step := range ordered {
+		latestFinishTimes[step.Name] = jobFinishTime
+	}
+	for i := len(ordered) - 1; i >= 0; i-- {
+		step := ordered[i]
+		latestStartTime := latestFinishTimes[step.Name].Add(-finishTimes[step.Name].Sub(startTimes[step.Name]))
+		for _, dependency := range step.DependsOn {
+			if latestStartTime.Before(latestFinishTimes[dependency]) {
+				latestFinishTimes[dependency] = latestStartTime
+			}
+		}
+	}
+	for _, step := range ordered {
+		criticalPath.Slack[step.Name] = max(0, latestFinishTimes[step.Name].Sub(finishTimes[step.Name]))
+	}
+	for _, pathStep := range criticalPath.Steps {
+		criticalPath.Slack[pathStep.Name] = 0
+	}
+
+	return criticalPath, nil
+}
+
+// Highlight wraps a renderer to highlight steps and edges on the critical path, like
+//
+//	jobInstance.Render(w, criticalPath.Highlight(graph.DotRenderer{}))
+func (cp *CriticalPath) Highlight(renderer graph.Renderer) graph.Renderer {
+	return &criticalPathRenderer{criticalPath: cp, renderer: renderer}
+}
+
+type criticalPathRenderer struct {
+	criticalPath *CriticalPath
+	renderer     graph.Renderer
+}
+
+func (r *criticalPathRenderer) Render(w io.Writer, g *graph.RenderGraph) error {
+	onPath := make(map[string]bool, len(r.criticalPath.Steps))
+	criticalEdges := make(map[[2]string]bool, len(r.criticalPath.Steps))
+	for i, step := range r.criticalPath.Steps {
+		onPath[step.Name] = true
+		if i > 0 {
+			criticalEdges[[2]string{r.criticalPath.Steps[i-1].Name, step.Name}] = true
+		}
+	}
+
+	for _, node := range g.Nodes {
+		if onPath[node.Name] {
+			node.Attributes = withCriticalAttributes(node.Attributes)
+		}
+	}
+	for _, edge := range g.Edges {
+		if criticalEdges[[2]string{edge.From, edge.To}] {
+			edge.Attributes = withCriticalAttributes(edge.Attributes)
+		}
+	}
+
+	return r.renderer.Render(w, g)
+}
+
+func withCriticalAttributes(attributes map[string]string) map[string]string {
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+	attributes["color"] = "purple"
+	attributes["penwidth"] = "3"
+	return attributes
+}
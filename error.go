@@ -3,13 +3,17 @@ package asyncjob
 import (
 	"errors"
 	"fmt"
+	"runtime/debug"
+	"strings"
 )
 
 type JobErrorCode string
 
 const (
 	ErrPrecedentStepFailed JobErrorCode = "PrecedentStepFailed"
+	MsgPrecedentStepFailed string       = "step %q is blocked, precedent step %q failed"
 	ErrStepFailed          JobErrorCode = "StepFailed"
+	ErrStepPanicked        JobErrorCode = "StepPanicked"
 
 	ErrRefStepNotInJob JobErrorCode = "RefStepNotInJob"
 	MsgRefStepNotInJob string       = "trying to reference to step %q, but it is not registered in job"
@@ -20,11 +24,62 @@ const (
 	ErrAddExistingStep JobErrorCode = "AddExistingStep"
 	MsgAddExistingStep string       = "trying to add step %q to job definition, but it already exists"
 
+	ErrReservedStepName JobErrorCode = "ReservedStepName"
+	MsgReservedStepName string       = "step name %q is reserved by asyncjob"
+
+	ErrInvalidStepGraph JobErrorCode = "InvalidStepGraph"
+	MsgInvalidStepGraph string       = "adding step %q breaks the steps graph: %s"
+
 	ErrDuplicateInputParentStep JobErrorCode = "DuplicateInputParentStep"
 	MsgDuplicateInputParentStep string       = "at least 2 input parentSteps are same"
 
 	ErrRuntimeStepNotFound JobErrorCode = "RuntimeStepNotFound"
 	MsgRuntimeStepNotFound string       = "runtime step %q not found, must be a bug in asyncjob"
+
+	ErrRuntimeStepTypeMismatch JobErrorCode = "RuntimeStepTypeMismatch"
+	MsgRuntimeStepTypeMismatch string       = "runtime step %q is %T, expected %T, must be a bug in asyncjob"
+	MsgRuntimeJobTypeMismatch  string       = "runtime job instance of step %q is %T, expected %T, must be a bug in asyncjob"
+
+	ErrSaveStepState    JobErrorCode = "SaveStepState"
+	ErrRestoreStepState JobErrorCode = "RestoreStepState"
+
+	ErrStateStoreNotConfigured JobErrorCode = "StateStoreNotConfigured"
+	MsgStateStoreNotConfigured string       = "resume job instance %q requires a StateStore, use WithStateStore"
+
+	ErrDefinitionChanged JobErrorCode = "DefinitionChanged"
+
+	ErrFingerprintMissing JobErrorCode = "FingerprintMissing"
+	MsgFingerprintMissing string       = "state of job instance %q has no definition fingerprint, use WithoutFingerprintCheck to resume it anyway"
+
+	ErrDuplicateJobDefinition JobErrorCode = "DuplicateJobDefinition"
+	MsgDuplicateJobDefinition string       = "another job definition with name %q is already registered"
+
+	ErrJobDefinitionNotRegistered JobErrorCode = "JobDefinitionNotRegistered"
+	MsgJobDefinitionNotRegistered string       = "job definition %q is not registered in the runner"
+
+	ErrJobInstanceNotFound JobErrorCode = "JobInstanceNotFound"
+	MsgJobInstanceNotFound string       = "job instance %q not found"
+
+	ErrJobInstanceIdConflict JobErrorCode = "JobInstanceIdConflict"
+	MsgJobInstanceIdConflict string       = "job instance %q already exists with job definition %q"
+
+	ErrJobInstanceNotFinished JobErrorCode = "JobInstanceNotFinished"
+	MsgJobInstanceNotFinished string       = "job instance %q is not finished yet, current state: %s"
+
+	ErrConcurrencyKeyConflict JobErrorCode = "ConcurrencyKeyConflict"
+	MsgConcurrencyKeyConflict string       = "job instance %q with concurrency key %q is not finished yet"
+
+	ErrUnknownConcurrencyPolicy JobErrorCode = "UnknownConcurrencyPolicy"
+	MsgUnknownConcurrencyPolicy string       = "concurrency policy %q of concurrency key %q is unknown"
+
+	ErrUnsupportedVisualizeFormat JobErrorCode = "UnsupportedVisualizeFormat"
+	MsgUnsupportedVisualizeFormat string       = "visualize format %q is not supported"
+
+	ErrSubscriptionAttached JobErrorCode = "SubscriptionAttached"
+	MsgSubscriptionAttached string       = "event subscription is attached to another job instance, cannot attach it to job instance %q"
+
+	ErrLoadJobState JobErrorCode = "LoadJobState"
+	MsgLoadJobState string       = "failed to load state of job instance %q: %s"
 )
 
 func (code JobErrorCode) Error() string {
@@ -63,6 +118,15 @@ func (je *JobError) Error() string {
 	if je.Code == ErrStepFailed && je.StepError != nil {
 		return fmt.Sprintf("step %q failed: %s", je.StepInstance.GetName(), je.StepError.Error())
 	}
+	if je.Code == ErrStepPanicked && je.StepError != nil {
+		return fmt.Sprintf("step %q panicked: %s", je.StepInstance.GetName(), je.StepError.Error())
+	}
+	if je.Code == ErrSaveStepState && je.StepError != nil {
+		return fmt.Sprintf("step %q failed to save state: %s", je.StepInstance.GetName(), je.StepError.Error())
+	}
+	if je.Code == ErrRestoreStepState && je.StepError != nil {
+		return fmt.Sprintf("step %q failed to restore state: %s", je.StepInstance.GetName(), je.StepError.Error())
+	}
 	return je.Code.Error() + ": " + je.Message
 }
 
@@ -73,7 +137,7 @@ func (je *JobError) Unwrap() error {
 // RootCause track precendent chain and return the first step raised this error.
 func (je *JobError) RootCause() error {
 	// this step failed, return the error
-	if je.Code == ErrStepFailed {
+	if je.Code == ErrStepFailed || je.Code == ErrStepPanicked {
 		return je
 	}
 
@@ -89,3 +153,67 @@ func (je *JobError) RootCause() error {
 	// no idea
 	return je
 }
+
+// PanicError is the error of a step panicked, in step function or ContextPolicy.
+type PanicError struct {
+	// Value passed to panic.
+	Value any
+	// Stack of the goroutine panicked.
+	Stack []byte
+}
+
+func newPanicError(value any) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("panic caught: %v, StackTrace: %s", pe.Value, pe.Stack)
+}
+
+// Unwrap returns the value passed to panic if it is an error.
+func (pe *PanicError) Unwrap() error {
+	if err, ok := pe.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// StepFailure is an independent failure of a step, along with steps blocked by it.
+type StepFailure struct {
+	StepName string
+	Code     JobErrorCode
+	Err      *JobError
+	// BlockedSteps are downstream steps not executed because of this failure, ordered topologically.
+	BlockedSteps []string
+}
+
+func (sf *StepFailure) Error() string {
+	return sf.Err.Error()
+}
+
+func (sf *StepFailure) Unwrap() error {
+	return sf.Err
+}
+
+// MultiJobError holds every independent step failure of a job instance, returned by JobInstance.WaitAll.
+//
+//	errors.Is and errors.As check every failure, errors.As(err, &jobErr) gives the first one.
+type MultiJobError struct {
+	Failures []*StepFailure
+}
+
+func (me *MultiJobError) Error() string {
+	messages := make([]string, 0, len(me.Failures))
+	for _, failure := range me.Failures {
+		messages = append(messages, failure.Error())
+	}
+	return fmt.Sprintf("%d steps failed: %s", len(me.Failures), strings.Join(messages, "; "))
+}
+
+func (me *MultiJobError) Unwrap() []error {
+	errs := make([]error, 0, len(me.Failures))
+	for _, failure := range me.Failures {
+		errs = append(errs, failure)
+	}
+	return errs
+}
@@ -0,0 +1,220 @@
+package asyncjob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// fingerprintStateKey is the StateStore key to persist definition fingerprint of a job instance.
+const fingerprintStateKey = "asyncjob.fingerprint"
+
+// DefinitionFingerprint describes the shape of a JobDefinition: steps, their output types and how they are wired.
+//
+//	persisted state from a job instance is only safe to resume with a definition having same fingerprint.
+type DefinitionFingerprint struct {
+	Hash string `json:"hash"`
+	// Steps ordered by name
+	Steps []*StepFingerprint `json:"steps"`
+}
+
+// StepFingerprint describes a step in DefinitionFingerprint.
+type StepFingerprint struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	OutputType string `json:"outputType"`
+	// DependsOn ordered by name
+	DependsOn []string `json:"dependsOn"`
+}
+
+// FingerprintDiff lists the steps changed between two fingerprints.
+type FingerprintDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Rewired steps have different dependencies.
+	Rewired []string `json:"rewired,omitempty"`
+	// Retyped steps have different step type or output type.
+	Retyped []string `json:"retyped,omitempty"`
+}
+
+func newDefinitionFingerprint(steps map[string]StepDefinitionMeta) *DefinitionFingerprint {
+	fingerprint := &DefinitionFingerprint{}
+	for _, step := range steps {
+		fingerprint.Steps = append(fingerprint.Steps, step.fingerprint())
+	}
+	sort.Slice(fingerprint.Steps, func(i, j int) bool {
+		return fingerprint.Steps[i].Name < fingerprint.Steps[j].Name
+	})
+
+	// steps are plain data with sorted slices, JSON is canonical.
+	stepsJson, _ := json.Marshal(fingerprint.Steps)
+	hash := sha256.Sum256(stepsJson)
+	fingerprint.Hash = hex.EncodeToString(hash[:])
+
+	return fingerprint
+}
+
+func newStepFingerprint(name string, stepType stepType, outputType reflect.Type, dependsOn []string) *StepFingerprint {
+	sortedDependsOn := append([]string{}, dependsOn...)
+	sort.Strings(sortedDependsOn)
+
+	return &StepFingerprint{
+		Name:       name,
+		Type:       string(stepType),
+		OutputType: qualifiedTypeName(outputType),
+		DependsOn:  sortedDependsOn,
+	}
+}
+
+// qualifiedTypeName names the type with full package path, like *github.com/org/pkg.Result,
+// so same-named types from different packages are told apart. composite types are named recursively.
+func qualifiedTypeName(t reflect.Type) string {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			// predeclared types, like int and error
+			return t.Name()
+		}
+		return t.PkgPath() + "." + t.Name()
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + qualifiedTypeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + qualifiedTypeName(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), qualifiedTypeName(t.Elem()))
+	case reflect.Map:
+		return fmt.Sprintf("map[%s]%s", qualifiedTypeName(t.Key()), qualifiedTypeName(t.Elem()))
+	case reflect.Chan:
+		switch t.ChanDir() {
+		case reflect.RecvDir:
+			return "<-chan " + qualifiedTypeName(t.Elem())
+		case reflect.SendDir:
+			return "chan<- " + qualifiedTypeName(t.Elem())
+		default:
+			return "chan " + qualifiedTypeName(t.Elem())
+		}
+	case reflect.Func:
+		params := make([]string, 0, t.NumIn())
+		for i := 0; i < t.NumIn(); i++ {
+			if t.IsVariadic() && i == t.NumIn()-1 {
+				params = append(params, "..."+qualifiedTypeName(t.In(i).Elem()))
+				continue
+			}
+			params = append(params, qualifiedTypeName(t.In(i)))
+		}
+		results := make([]string, 0, t.NumOut())
+		for i := 0; i < t.NumOut(); i++ {
+			results = append(results, qualifiedTypeName(t.Out(i)))
+		}
+		return fmt.Sprintf("func(%s) (%s)", strings.Join(params, ", "), strings.Join(results, ", "))
+	case reflect.Struct:
+		fields := make([]string, 0, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := field.Name
+			if field.PkgPath != "" {
+				// unexported field, qualified by the package declaring it
+				name = field.PkgPath + "." + name
+			}
+			if field.Anonymous {
+				name = "embedded " + name
+			}
+			fields = append(fields, fmt.Sprintf("%s %s %q", name, qualifiedTypeName(field.Type), field.Tag))
+		}
+		return "struct { " + strings.Join(fields, "; ") + " }"
+	case reflect.Interface:
+		methods := make([]string, 0, t.NumMethod())
+		for i := 0; i < t.NumMethod(); i++ {
+			method := t.Method(i)
+			name := method.Name
+			if method.PkgPath != "" {
+				name = method.PkgPath + "." + name
+			}
+			methods = append(methods, name+" "+qualifiedTypeName(method.Type))
+		}
+		return "interface { " + strings.Join(methods, "; ") + " }"
+	default:
+		return t.String()
+	}
+}
+
+// Diff returns steps changed from fp to other.
+func (fp *DefinitionFingerprint) Diff(other *DefinitionFingerprint) *FingerprintDiff {
+	diff := &FingerprintDiff{}
+	steps := make(map[string]*StepFingerprint)
+	for _, step := range fp.Steps {
+		steps[step.Name] = step
+	}
+
+	otherSteps := make(map[string]*StepFingerprint)
+	for _, otherStep := range other.Steps {
+		otherSteps[otherStep.Name] = otherStep
+
+		step, ok := steps[otherStep.Name]
+		if !ok {
+			diff.Added = append(diff.Added, otherStep.Name)
+			continue
+		}
+
+		if step.Type != otherStep.Type || step.OutputType != otherStep.OutputType {
+			diff.Retyped = append(diff.Retyped, otherStep.Name)
+		}
+		if !reflect.DeepEqual(step.DependsOn, otherStep.DependsOn) {
+			diff.Rewired = append(diff.Rewired, otherStep.Name)
+		}
+	}
+
+	for _, step := range fp.Steps {
+		if _, ok := otherSteps[step.Name]; !ok {
+			diff.Removed = append(diff.Removed, step.Name)
+		}
+	}
+
+	return diff
+}
+
+// Empty tells whether the two fingerprints are same.
+func (diff *FingerprintDiff) Empty() bool {
+	return len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Rewired) == 0 && len(diff.Retyped) == 0
+}
+
+func (diff *FingerprintDiff) String() string {
+	var parts []string
+	for _, section := range []struct {
+		name  string
+		steps []string
+	}{
+		{"added", diff.Added},
+		{"removed", diff.Removed},
+		{"rewired", diff.Rewired},
+		{"retyped", diff.Retyped},
+	} {
+		if len(section.steps) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s", section.name, strings.Join(section.steps, ", ")))
+		}
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// FingerprintMismatchError is returned when resuming a job instance persisted with a different definition.
+type FingerprintMismatchError struct {
+	JobId     string
+	Persisted *DefinitionFingerprint
+	Current   *DefinitionFingerprint
+	Diff      *FingerprintDiff
+}
+
+func (fme *FingerprintMismatchError) Error() string {
+	return fmt.Sprintf("%s: job instance %q was persisted with a different definition (%s)", ErrDefinitionChanged, fme.JobId, fme.Diff)
+}
+
+func (fme *FingerprintMismatchError) Unwrap() error {
+	return ErrDefinitionChanged
+}
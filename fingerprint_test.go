@@ -0,0 +1,140 @@
+package asyncjob_test
+
+import (
+	"context"
+	"errors"
+	htmltemplate "html/template"
+	"testing"
+	texttemplate "text/template"
+
+	"github.com/Azure/go-asyncjob"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefinitionFingerprint(t *testing.T) {
+	t.Parallel()
+
+	jd1, err := BuildJob(map[string]asyncjob.RetryPolicy{})
+	assert.NoError(t, err)
+	jd2, err := BuildJob(map[string]asyncjob.RetryPolicy{
+		"GetConnection": newLinearRetryPolicy(1, 3),
+	})
+	assert.NoError(t, err)
+
+	// retry policy doesn't change the shape of the definition
+	jd1.Seal()
+	fingerprint1 := jd1.Fingerprint()
+	assert.Len(t, fingerprint1.Steps, 9)
+	assert.Equal(t, fingerprint1.Hash, jd2.Fingerprint().Hash)
+	assert.True(t, fingerprint1.Diff(jd2.Fingerprint()).Empty())
+
+	for _, step := range fingerprint1.Steps {
+		switch step.Name {
+		case "sqlSummaryJob":
+			assert.Equal(t, "root", step.Type)
+			assert.Equal(t, "*github.com/Azure/go-asyncjob_test.SqlSummaryJobLib", step.OutputType)
+			assert.Empty(t, step.DependsOn)
+		case "QueryTable1":
+			assert.Equal(t, "task", step.Type)
+			assert.Equal(t, "*github.com/Azure/go-asyncjob_test.SqlQueryResult", step.OutputType)
+			assert.Equal(t, []string{"CheckAuth", "GetTableClient1"}, step.DependsOn)
+		}
+	}
+
+	// rewire QueryTable1, retype CheckAuth, remove EmailNotification, add Audit
+	jd3 := asyncjob.NewJobDefinition[*SqlSummaryJobLib]("sqlSummaryJob")
+	connTsk, _ := asyncjob.AddStep(jd3, "GetConnection", connectionStepFunc)
+	_, _ = asyncjob.AddStepWithStaticFunc(jd3, "CheckAuth", func(ctx context.Context) (string, error) { return "", nil })
+	table1ClientTsk, _ := asyncjob.StepAfter(jd3, "GetTableClient1", connTsk, tableClient1StepFunc)
+	query1Task, _ := asyncjob.StepAfter(jd3, "QueryTable1", table1ClientTsk, queryTable1StepFunc)
+	table2ClientTsk, _ := asyncjob.StepAfter(jd3, "GetTableClient2", connTsk, tableClient2StepFunc)
+	query2Task, _ := asyncjob.StepAfter(jd3, "QueryTable2", table2ClientTsk, queryTable2StepFunc)
+	_, _ = asyncjob.StepAfterBoth(jd3, "Summarize", query1Task, query2Task, summarizeQueryResultStepFunc)
+	_, err = asyncjob.AddStep(jd3, "Audit", connectionStepFunc)
+	assert.NoError(t, err)
+
+	diff := fingerprint1.Diff(jd3.Fingerprint())
+	assert.False(t, diff.Empty())
+	assert.Equal(t, []string{"Audit"}, diff.Added)
+	assert.Equal(t, []string{"EmailNotification"}, diff.Removed)
+	assert.Equal(t, []string{"QueryTable1", "QueryTable2"}, diff.Rewired)
+	assert.Equal(t, []string{"CheckAuth"}, diff.Retyped)
+	assert.Equal(t, "added: Audit; removed: EmailNotification; rewired: QueryTable1, QueryTable2; retyped: CheckAuth", diff.String())
+
+	// resume with a changed definition is refused
+	store := asyncjob.NewInMemoryStateStore()
+	jobInstance := jd1.Start(context.Background(), NewSqlJobLib(&SqlSummaryJobParameters{ServerName: "server1"}), asyncjob.WithJobId("fingerprintJob"), asyncjob.WithStateStore(store))
+	assert.NoError(t, jobInstance.Wait(context.Background()))
+
+	_, err = jd3.Resume(context.Background(), "fingerprintJob", NewSqlJobLib(&SqlSummaryJobParameters{ServerName: "server1"}), asyncjob.WithStateStore(store))
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, asyncjob.ErrDefinitionChanged))
+	mismatchErr := &asyncjob.FingerprintMismatchError{}
+	assert.True(t, errors.As(err, &mismatchErr))
+	assert.Equal(t, []string{"Audit"}, mismatchErr.Diff.Added)
+	assert.Equal(t, fingerprint1.Hash, mismatchErr.Persisted.Hash)
+
+	resumedInstance, err := jd2.Resume(context.Background(), "fingerprintJob", NewSqlJobLib(&SqlSummaryJobParameters{ServerName: "server1"}), asyncjob.WithStateStore(store))
+	assert.NoError(t, err)
+	assert.NoError(t, resumedInstance.Wait(context.Background()))
+}
+
+func TestDefinitionFingerprintQualifiedType(t *testing.T) {
+	t.Parallel()
+
+	// both named template.Template, only package path tells them apart.
+	textJob := asyncjob.NewJobDefinition[string]("templateJob")
+	_, err := asyncjob.AddStepWithStaticFunc(textJob, "Parse", func(ctx context.Context) (map[string][]*texttemplate.Template, error) { return nil, nil })
+	assert.NoError(t, err)
+	htmlJob := asyncjob.NewJobDefinition[string]("templateJob")
+	_, err = asyncjob.AddStepWithStaticFunc(htmlJob, "Parse", func(ctx context.Context) (map[string][]*htmltemplate.Template, error) { return nil, nil })
+	assert.NoError(t, err)
+
+	for _, step := range textJob.Fingerprint().Steps {
+		if step.Name == "Parse" {
+			assert.Equal(t, "map[string][]*text/template.Template", step.OutputType)
+		}
+	}
+	diff := textJob.Fingerprint().Diff(htmlJob.Fingerprint())
+	assert.Equal(t, []string{"Parse"}, diff.Retyped)
+	assert.NotEqual(t, textJob.Fingerprint().Hash, htmlJob.Fingerprint().Hash)
+}
+
+func TestJobResumeWithoutFingerprint(t *testing.T) {
+	t.Parallel()
+
+	job := asyncjob.NewJobDefinition[string]("noFingerprintJob")
+	_, err := asyncjob.AddStepWithStaticFunc(job, "Echo", func(ctx context.Context) (string, error) {
+		return "echo", nil
+	})
+	assert.NoError(t, err)
+
+	// state persisted by something else than asyncjob, without fingerprint.
+	store := asyncjob.NewInMemoryStateStore()
+	assert.NoError(t, store.Save(context.Background(), "noFingerprintJob1", "Echo", []byte(`"echo"`)))
+
+	_, err = job.Resume(context.Background(), "noFingerprintJob1", "input", asyncjob.WithStateStore(store))
+	assert.ErrorIs(t, err, asyncjob.ErrFingerprintMissing)
+
+	jobInstance, err := job.Resume(context.Background(), "noFingerprintJob1", "input", asyncjob.WithStateStore(store), asyncjob.WithoutFingerprintCheck())
+	assert.NoError(t, err)
+	assert.NoError(t, jobInstance.Wait(context.Background()))
+
+	// nothing persisted yet, no fingerprint is expected.
+	jobInstance, err = job.Resume(context.Background(), "noFingerprintJob2", "input", asyncjob.WithStateStore(store))
+	assert.NoError(t, err)
+	assert.NoError(t, jobInstance.Wait(context.Background()))
+}
+
+func TestFingerprintStepNameReserved(t *testing.T) {
+	t.Parallel()
+
+	// step output would overwrite the fingerprint in StateStore.
+	job := asyncjob.NewJobDefinition[string]("reservedJob")
+	_, err := asyncjob.AddStepWithStaticFunc(job, "asyncjob.fingerprint", func(ctx context.Context) (string, error) {
+		return "echo", nil
+	})
+	assert.ErrorIs(t, err, asyncjob.ErrReservedStepName)
+	_, ok := job.GetStep("asyncjob.fingerprint")
+	assert.False(t, ok)
+}
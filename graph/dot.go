@@ -0,0 +1,212 @@
+package graph
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// AttributeHTMLLabel is the attribute for a graphviz HTML-like label, rendered as label=<...> by DotRenderer.
+//
+//	https://graphviz.org/doc/info/shapes.html#html
+//	value should be well-formed markup, it is validated but not escaped. other renderers fall back to "label".
+const AttributeHTMLLabel = "htmllabel"
+
+// DotFuncs are the template functions used by DotRenderer, add them to a custom DOT template with template.Funcs.
+//
+//	dotString quotes and escapes a string as DOT ID.
+//	dotAttribute renders a single graph attribute statement.
+//	dotAttributes renders an attribute map as a DOT attribute list, values are escaped, shapes, styles and colors are validated.
+var DotFuncs = template.FuncMap{
+	"dotString":     dotString,
+	"dotAttribute":  dotAttribute,
+	"dotAttributes": dotAttributes,
+}
+
+var (
+	dotIdentifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	dotColorRegex      = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9]*|#[0-9A-Fa-f]{6}([0-9A-Fa-f]{2})?|/[A-Za-z0-9]*/[A-Za-z0-9]+)$`)
+	dotHSVSeparator    = regexp.MustCompile(`[, ]+`)
+)
+
+// https://graphviz.org/doc/info/shapes.html
+var dotShapes = newStringSet(
+	"box", "polygon", "ellipse", "oval", "circle", "point", "egg", "triangle", "plaintext", "plain",
+	"diamond", "trapezium", "parallelogram", "house", "pentagon", "hexagon", "septagon", "octagon",
+	"doublecircle", "doubleoctagon", "tripleoctagon", "invtriangle", "invtrapezium", "invhouse",
+	"Mdiamond", "Msquare", "Mcircle", "rect", "rectangle", "square", "star", "none", "underline",
+	"cylinder", "note", "tab", "folder", "box3d", "component", "promoter", "cds", "terminator", "utr",
+	"primersite", "restrictionsite", "fivepoverhang", "threepoverhang", "noverhang", "assembly",
+	"signature", "insulator", "ribosite", "rnastab", "proteasesite", "proteinstab", "rpromoter",
+	"rarrow", "larrow", "lpromoter", "record", "Mrecord",
+)
+
+// https://graphviz.org/docs/attr-types/style/
+var dotStyles = newStringSet(
+	"solid", "dashed", "dotted", "bold", "invis", "filled", "striped", "wedged", "diagonals", "rounded", "radial", "tapered",
+)
+
+func newStringSet(values ...string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// dotString quotes s as a DOT double-quoted string.
+//
+//	newline is rendered as \n line break, tab is kept, other control characters and invalid UTF-8 bytes are shown as \xNN text,
+//	so distinct strings stay distinct, and the value never breaks out of the quotes.
+func dotString(s string) string {
+	sb := &strings.Builder{}
+	sb.WriteByte('"')
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(sb, `\\x%02x`, s[0])
+		case r == '"':
+			sb.WriteString(`\"`)
+		case r == '\\':
+			sb.WriteString(`\\`)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\t':
+			sb.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			// escaped backslash, graphviz shows it as \xNN text.
+			fmt.Fprintf(sb, `\\x%02x`, r)
+		default:
+			sb.WriteRune(r)
+		}
+		s = s[size:]
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// dotAttribute renders a graph attribute, like `rankdir = "LR"`.
+func dotAttribute(key, value string) (string, error) {
+	if err := validateDotAttribute(key, value); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s = %s", key, dotString(value)), nil
+}
+
+// dotAttributes renders attributes ordered by key, like `color="red" label="a"`.
+func dotAttributes(attributes map[string]string) (string, error) {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	_, hasHTMLLabel := attributes[AttributeHTMLLabel]
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := attributes[key]
+		if key == AttributeHTMLLabel {
+			if err := validateHTMLLabel(value); err != nil {
+				return "", err
+			}
+			parts = append(parts, fmt.Sprintf("label=<%s>", value))
+			continue
+		}
+		if key == "label" && hasHTMLLabel {
+			continue
+		}
+
+		if err := validateDotAttribute(key, value); err != nil {
+			return "", err
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", key, dotString(value)))
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+func validateDotAttribute(key, value string) error {
+	if !dotIdentifierRegex.MatchString(key) {
+		return NewGraphError(ErrInvalidAttribute, fmt.Sprintf("attribute name %q is not a valid identifier", key))
+	}
+
+	switch {
+	case key == "shape":
+		if !dotShapes[value] {
+			return NewGraphError(ErrInvalidAttribute, fmt.Sprintf("shape %q is not a graphviz shape", value))
+		}
+	case key == "style":
+		for _, style := range strings.Split(value, ",") {
+			if !dotStyles[strings.TrimSpace(style)] {
+				return NewGraphError(ErrInvalidAttribute, fmt.Sprintf("style %q is not a graphviz style", style))
+			}
+		}
+	case strings.HasSuffix(key, "color"):
+		if !isDotColorList(value) {
+			return NewGraphError(ErrInvalidAttribute, fmt.Sprintf("%s %q is not a color name, #rrggbb, HSV or color list", key, value))
+		}
+	}
+
+	return nil
+}
+
+// isDotColorList tells whether value is a graphviz color list, like "red", "0.6 0.5 1.0" or "red;0.3:#0000ff".
+//
+//	https://graphviz.org/docs/attr-types/colorList/
+func isDotColorList(value string) bool {
+	for _, item := range strings.Split(value, ":") {
+		color, weight, weighted := strings.Cut(item, ";")
+		if weighted && !isDotUnitFloat(weight) {
+			return false
+		}
+		if !isDotColor(color) {
+			return false
+		}
+	}
+	return true
+}
+
+// isDotColor tells whether value is a color name, /scheme/name, #rrggbb(aa) or "H,S,V" with each of them in [0, 1].
+func isDotColor(value string) bool {
+	if dotColorRegex.MatchString(value) {
+		return true
+	}
+
+	hsv := dotHSVSeparator.Split(strings.TrimSpace(value), -1)
+	if len(hsv) != 3 {
+		return false
+	}
+	for _, component := range hsv {
+		if !isDotUnitFloat(component) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDotUnitFloat(value string) bool {
+	f, err := strconv.ParseFloat(value, 64)
+	return err == nil && f >= 0 && f <= 1
+}
+
+// validateHTMLLabel checks the label is well-formed markup, so it cannot close the label=<...> early.
+func validateHTMLLabel(label string) error {
+	decoder := xml.NewDecoder(strings.NewReader("<label>" + label + "</label>"))
+	decoder.Strict = true
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return NewGraphError(ErrInvalidAttribute, fmt.Sprintf("html label is not well-formed: %s", err))
+		}
+	}
+}
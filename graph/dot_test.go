@@ -0,0 +1,164 @@
+package graph_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Azure/go-asyncjob/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+type specNode struct {
+	spec *graph.DotNodeSpec
+}
+
+func (sn *specNode) GetName() string {
+	return sn.spec.Name
+}
+
+func (sn *specNode) DotSpec() *graph.DotNodeSpec {
+	return sn.spec
+}
+
+func noEdgeSpec(from, to *specNode) *graph.DotEdgeSpec {
+	return &graph.DotEdgeSpec{FromNodeName: from.GetName(), ToNodeName: to.GetName()}
+}
+
+func TestDotEscaping(t *testing.T) {
+	g := graph.NewGraph(noEdgeSpec)
+	g.AddNode(&specNode{&graph.DotNodeSpec{
+		Name:        `evil" shape=star fillcolor="red`,
+		DisplayName: "line1\nline2 \\ end",
+		Tooltip:     "error: \"quoted\"\r\n\tdone",
+	}})
+
+	dot, err := g.ToDotGraph()
+	assert.NoError(t, err)
+	t.Log(dot)
+	assert.Contains(t, dot, `"evil\" shape=star fillcolor=\"red" [label="line1\nline2 \\ end" tooltip="error: \"quoted\"\\x0d\n`+"\t"+`done"]`)
+
+	// control characters and invalid UTF-8 are shown as text, names differ only by them don't collide.
+	g = graph.NewGraph(noEdgeSpec)
+	g.AddNode(&specNode{&graph.DotNodeSpec{Name: "step\x01"}})
+	g.AddNode(&specNode{&graph.DotNodeSpec{Name: "step\x02\xff"}})
+	dot, err = g.ToDotGraph()
+	assert.NoError(t, err)
+	assert.Contains(t, dot, `"step\\x01"`)
+	assert.Contains(t, dot, `"step\\x02\\xff"`)
+}
+
+func TestDotAttributeValidation(t *testing.T) {
+	for name, spec := range map[string]*graph.DotNodeSpec{
+		"shape":          {Name: "a", Shape: `box" color="red`},
+		"style":          {Name: "a", Style: "filled,glowing"},
+		"fillcolor":      {Name: "a", FillColor: "red;blue"},
+		"hsv range":      {Name: "a", FillColor: "0.5 0.5 2"},
+		"hsv length":     {Name: "a", FillColor: "0.5,0.5"},
+		"color list":     {Name: "a", FillColor: "red:"},
+		"weight range":   {Name: "a", FillColor: "red;1.5:blue"},
+		"attribute name": {Name: "a", Attributes: map[string]string{"bad key": "x"}},
+		"html label":     {Name: "a", Attributes: map[string]string{graph.AttributeHTMLLabel: "<b>bold</b>> shape=star <"}},
+	} {
+		g := graph.NewGraph(noEdgeSpec)
+		g.AddNode(&specNode{spec})
+		_, err := g.ToDotGraph()
+		assert.Error(t, err, name)
+		assert.True(t, errors.Is(err, graph.ErrInvalidAttribute), name)
+	}
+
+	g := graph.NewGraph(noEdgeSpec)
+	g.AddNode(&specNode{&graph.DotNodeSpec{
+		Name:       "valid",
+		Shape:      "Mrecord",
+		Style:      "filled, dashed",
+		FillColor:  "#FF000080",
+		Attributes: map[string]string{"fontcolor": "/blues9/3", graph.AttributeHTMLLabel: `<b>bold</b><br/>&amp; normal`},
+	}})
+	dot, err := g.ToDotGraph()
+	assert.NoError(t, err)
+	assert.Contains(t, dot, `"valid" [fillcolor="#FF000080" fontcolor="/blues9/3" label=<<b>bold</b><br/>&amp; normal> shape="Mrecord" style="filled, dashed"]`)
+
+	// HSV and color lists, with optional weights
+	for _, color := range []string{"0.000 1.000 1.000", "0.6,0.5, .9", "red:blue", "red;0.3:#0000ff", "yellow;0.2:0.6 0.5 1:/blues9/3"} {
+		g := graph.NewGraph(noEdgeSpec)
+		g.AddNode(&specNode{&graph.DotNodeSpec{Name: "colored", FillColor: color}})
+		dot, err := g.ToDotGraph()
+		assert.NoError(t, err, color)
+		assert.Contains(t, dot, `fillcolor="`+color+`"`)
+	}
+}
+
+// unquoteDot reads a DOT double-quoted string at the beginning of s, returns its value and rest of s.
+func unquoteDot(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", s, false
+	}
+	sb := &strings.Builder{}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return sb.String(), s[i+1:], true
+		case '\\':
+			i++
+			if i < len(s) && s[i] == 'n' {
+				sb.WriteByte('\n')
+			} else if i < len(s) {
+				sb.WriteByte(s[i])
+			}
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return "", s, false
+}
+
+func FuzzDotEscaping(f *testing.F) {
+	for _, seed := range []string{"plain", `a"b`, "new\nline", `back\slash`, `trailing\`, "\r\n\t", "\xff\xfe", `"] [shape=star`, "日本語"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		g := graph.NewGraph(noEdgeSpec)
+		g.AddNode(&specNode{&graph.DotNodeSpec{Name: name, DisplayName: name + "!", Tooltip: name + "?"}})
+		dot, err := g.ToDotGraph()
+		assert.NoError(t, err)
+
+		// the node statement stays in one line, with exactly 3 quoted strings: id, label and tooltip.
+		lines := strings.Split(dot, "\n")
+		assert.Len(t, lines, 6)
+		statement := strings.TrimLeft(lines[2], "\t")
+
+		expected := &strings.Builder{}
+		for s := name; len(s) > 0; {
+			r, size := utf8.DecodeRuneInString(s)
+			switch {
+			case r == utf8.RuneError && size == 1:
+				fmt.Fprintf(expected, `\x%02x`, s[0])
+			case (r < 0x20 && r != '\n' && r != '\t') || r == 0x7f:
+				fmt.Fprintf(expected, `\x%02x`, r)
+			default:
+				expected.WriteRune(r)
+			}
+			s = s[size:]
+		}
+
+		id, rest, ok := unquoteDot(statement)
+		assert.True(t, ok)
+		assert.Equal(t, expected.String(), id)
+		assert.True(t, utf8.ValidString(id))
+
+		rest = strings.TrimPrefix(rest, " [label=")
+		label, rest, ok := unquoteDot(rest)
+		assert.True(t, ok)
+		assert.Equal(t, expected.String()+"!", label)
+
+		rest = strings.TrimPrefix(rest, " tooltip=")
+		tooltip, rest, ok := unquoteDot(rest)
+		assert.True(t, ok)
+		assert.Equal(t, expected.String()+"?", tooltip)
+		assert.Equal(t, "]", rest)
+	})
+}
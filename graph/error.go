@@ -5,6 +5,11 @@ type GraphCodeError string
 const (
 	ErrDuplicateNode          GraphCodeError = "node with same key already exists in this graph"
 	ErrConnectNotExistingNode GraphCodeError = "node to connect does not exist in this graph"
+	ErrInvalidAttribute       GraphCodeError = "attribute is not valid"
+	ErrNodeNotExists          GraphCodeError = "node does not exist in this graph"
+	ErrSelfLoop               GraphCodeError = "node is connected to itself"
+	ErrCycle                  GraphCodeError = "graph has a cycle"
+	ErrDuplicateEdge          GraphCodeError = "same edge is added more than once"
 )
 
 func (ge GraphCodeError) Error() string {
@@ -1,8 +1,9 @@
 package graph
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"strings"
 )
 
 // NodeConstrain is a constraint for a node in a graph
@@ -31,6 +32,8 @@ type DotNodeSpec struct {
 	Shape       string
 	Style       string
 	FillColor   string
+	// Attributes are rendered along with the fields above, and override them when using same name.
+	Attributes map[string]string
 }
 
 // DotEdgeSpec is the specification for an edge in DOT graph
@@ -40,22 +43,47 @@ type DotEdgeSpec struct {
 	Tooltip      string
 	Style        string
 	Color        string
+	// Attributes are rendered along with the fields above, and override them when using same name.
+	Attributes map[string]string
 }
 
 // Graph hold the nodes and edges of a graph
 type Graph[NT NodeConstrain] struct {
+	options      *GraphOptions
 	nodes        map[string]NT
+	nodeOrder    []NT
+	nodeIndex    map[string]int
+	nextIndex    int
 	nodeEdges    map[string][]*Edge[NT]
+	nodeInEdges  map[string][]*Edge[NT]
 	edgeSpecFunc EdgeSpecFunc[NT]
 }
 
-// NewGraph creates a new graph
-func NewGraph[NT NodeConstrain](edgeSpecFunc EdgeSpecFunc[NT]) *Graph[NT] {
-	return &Graph[NT]{
+// NewGraph creates a new graph, nodes are ordered by insertion unless other OrderRule is specified.
+//
+//	nil option is skipped, OrderByPriority without Priority orders by insertion.
+func NewGraph[NT NodeConstrain](edgeSpecFunc EdgeSpecFunc[NT], optionDecorators ...GraphOptionPreparer) *Graph[NT] {
+	g := &Graph[NT]{
+		options:      &GraphOptions{OrderRule: OrderByInsertion},
 		nodes:        make(map[string]NT),
+		nodeIndex:    make(map[string]int),
 		nodeEdges:    make(map[string][]*Edge[NT]),
+		nodeInEdges:  make(map[string][]*Edge[NT]),
 		edgeSpecFunc: edgeSpecFunc,
 	}
+
+	for _, decorator := range optionDecorators {
+		if decorator != nil {
+			g.options = decorator(g.options)
+		}
+	}
+
+	if g.options.OrderRule == OrderByPriority && g.options.Priority == nil {
+		// nothing to order by, keep insertion order instead of panic in the middle of a sort.
+		g.options.OrderRule = OrderByInsertion
+	}
+
+	return g
 }
 
 // AddNode adds a node to the graph
@@ -65,10 +93,17 @@ func (g *Graph[NT]) AddNode(n NT) error {
 		return NewGraphError(ErrDuplicateNode, fmt.Sprintf("node with key %s already exists in this graph", nodeKey))
 	}
 	g.nodes[nodeKey] = n
+	g.nodeIndex[nodeKey] = g.nextIndex
+	g.nextIndex++
+	g.nodeOrder = append(g.nodeOrder, n)
 
 	return nil
 }
 
+// Connect adds an edge from a node to another, both nodes should be added already.
+//
+//	self-loop is refused with ErrSelfLoop, edge creating a cycle is refused with ErrCycle.
+//	duplicate edge is accepted, and reported by Validate.
 func (g *Graph[NT]) Connect(from, to NT) error {
 	fromNodeKey := from.GetName()
 	toNodeKey := to.GetName()
@@ -81,50 +116,115 @@ func (g *Graph[NT]) Connect(from, to NT) error {
 		return NewGraphError(ErrConnectNotExistingNode, fmt.Sprintf("cannot connect node %s, it's not added in this graph yet", toNodeKey))
 	}
 
-	g.nodeEdges[fromNodeKey] = append(g.nodeEdges[fromNodeKey], &Edge[NT]{From: from, To: to})
+	if fromNodeKey == toNodeKey {
+		return NewGraphError(ErrSelfLoop, fmt.Sprintf("cannot connect node %s to itself", fromNodeKey))
+	}
+
+	if path := g.findPath(toNodeKey, fromNodeKey); path != nil {
+		return NewGraphError(ErrCycle, fmt.Sprintf("connecting %s -> %s creates a cycle: %s", fromNodeKey, toNodeKey, strings.Join(append([]string{fromNodeKey}, path...), " -> ")))
+	}
+
+	edge := &Edge[NT]{From: from, To: to}
+	g.nodeEdges[fromNodeKey] = append(g.nodeEdges[fromNodeKey], edge)
+	g.nodeInEdges[toNodeKey] = append(g.nodeInEdges[toNodeKey], edge)
 	return nil
 }
 
 // https://en.wikipedia.org/wiki/DOT_(graph_description_language)
 func (g *Graph[NT]) ToDotGraph() (string, error) {
-	nodes := make([]*DotNodeSpec, 0)
-	for _, node := range g.nodes {
-		nodes = append(nodes, node.DotSpec())
+	return g.renderToString(DotRenderer{})
+}
+
+// findPath returns node names on a path from src to dst (both included), nil if dst is not reachable from src.
+func (g *Graph[NT]) findPath(src, dst string) []string {
+	visited := make(map[string]bool)
+	var visit func(nodeKey string) []string
+	visit = func(nodeKey string) []string {
+		if nodeKey == dst {
+			return []string{dst}
+		}
+		visited[nodeKey] = true
+		for _, edge := range g.nodeEdges[nodeKey] {
+			toNodeKey := edge.To.GetName()
+			if visited[toNodeKey] {
+				continue
+			}
+			if path := visit(toNodeKey); path != nil {
+				return append([]string{nodeKey}, path...)
+			}
+		}
+		return nil
 	}
 
-	edges := make([]*DotEdgeSpec, 0)
-	for _, nodeEdges := range g.nodeEdges {
-		for _, edge := range nodeEdges {
-			edges = append(edges, g.edgeSpecFunc(edge.From, edge.To))
+	return visit(src)
+}
+
+// Validate checks the graph has no duplicate edges, self-loops or cycles, all problems found are joined in the error.
+func (g *Graph[NT]) Validate() error {
+	var errs []error
+	for _, node := range g.nodeOrder {
+		nodeKey := node.GetName()
+		connected := make(map[string]bool)
+		for _, edge := range g.nodeEdges[nodeKey] {
+			toNodeKey := edge.To.GetName()
+			if toNodeKey == nodeKey {
+				errs = append(errs, NewGraphError(ErrSelfLoop, fmt.Sprintf("node %s is connected to itself", nodeKey)))
+			} else if connected[toNodeKey] {
+				errs = append(errs, NewGraphError(ErrDuplicateEdge, fmt.Sprintf("node %s is connected to %s more than once", nodeKey, toNodeKey)))
+			}
+			connected[toNodeKey] = true
 		}
 	}
 
-	buf := new(bytes.Buffer)
-	err := digraphTemplate.Execute(buf, templateRef{Nodes: nodes, Edges: edges})
-	if err != nil {
-		return "", err
+	if cycle := g.findCycle(); cycle != nil {
+		errs = append(errs, NewGraphError(ErrCycle, fmt.Sprintf("graph has a cycle: %s", strings.Join(cycle, " -> "))))
 	}
-	return buf.String(), nil
-}
 
-func (g *Graph[NT]) TopologicalSort() []NT {
-	visited := make(map[string]bool)
-	stack := make([]NT, 0)
+	return errors.Join(errs...)
+}
 
-	for _, node := range g.nodes {
-		if !visited[node.GetName()] {
-			g.topologicalSortInternal(node, &visited, &stack)
+// findCycle returns node names on a cycle (first node repeated at the end), self-loops are not counted, nil if there is no cycle.
+func (g *Graph[NT]) findCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var stack []string
+	var visit func(nodeKey string) []string
+	visit = func(nodeKey string) []string {
+		state[nodeKey] = visiting
+		stack = append(stack, nodeKey)
+		for _, edge := range g.nodeEdges[nodeKey] {
+			toNodeKey := edge.To.GetName()
+			if toNodeKey == nodeKey {
+				continue
+			}
+			switch state[toNodeKey] {
+			case visiting:
+				for i, key := range stack {
+					if key == toNodeKey {
+						return append(append([]string{}, stack[i:]...), toNodeKey)
+					}
+				}
+			case unvisited:
+				if cycle := visit(toNodeKey); cycle != nil {
+					return cycle
+				}
+			}
 		}
+		stack = stack[:len(stack)-1]
+		state[nodeKey] = visited
+		return nil
 	}
-	return stack
-}
 
-func (g *Graph[NT]) topologicalSortInternal(node NT, visited *map[string]bool, stack *[]NT) {
-	(*visited)[node.GetName()] = true
-	for _, edge := range g.nodeEdges[node.GetName()] {
-		if !(*visited)[edge.To.GetName()] {
-			g.topologicalSortInternal(edge.To, visited, stack)
+	for _, node := range g.nodeOrder {
+		if state[node.GetName()] == unvisited {
+			if cycle := visit(node.GetName()); cycle != nil {
+				return cycle
+			}
 		}
 	}
-	*stack = append([]NT{node}, *stack...)
+	return nil
 }
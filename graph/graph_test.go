@@ -1,9 +1,12 @@
 package graph_test
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"text/template"
 
 	"github.com/Azure/go-asyncjob/graph"
 	"github.com/stretchr/testify/assert"
@@ -135,3 +138,330 @@ func edgeSpecFromConnection(from, to *testNode) *graph.DotEdgeSpec {
 		Color:        "black",
 	}
 }
+
+func TestMermaidGraph(t *testing.T) {
+	g := graph.NewGraph(edgeSpecFromConnection)
+	root := &testNode{Name: "root"}
+	g.AddNode(root)
+	quoted := &testNode{Name: `say "hi" #1`}
+	g.AddNode(quoted)
+	spaced := &testNode{Name: "step with <spaces>"}
+	g.AddNode(spaced)
+	g.Connect(root, quoted)
+	g.Connect(root, spaced)
+	g.Connect(quoted, spaced)
+
+	mermaid, err := g.ToMermaid()
+	assert.NoError(t, err)
+	t.Log(mermaid)
+
+	assert.Equal(t, `flowchart TD
+	n0["root"]:::s_green
+	n1["say #34;hi#34; #35;1"]:::s_green
+	n2["step with #60;spaces#62;"]:::s_green
+	n0 --> n1
+	n0 --> n2
+	n1 --> n2
+	classDef s_green fill:green
+	linkStyle 0 stroke:black
+	linkStyle 1 stroke:black
+	linkStyle 2 stroke:black
+`, mermaid)
+}
+
+func TestRenderers(t *testing.T) {
+	g := graph.NewGraph(edgeSpecFromConnection, graph.WithOrderByName())
+	root := &testNode{Name: "root"}
+	g.AddNode(root)
+	leaf := &testNode{Name: "leaf"}
+	g.AddNode(leaf)
+	g.Connect(root, leaf)
+
+	// dot with graph attributes
+	model := g.ToRenderGraph()
+	model.Attributes["rankdir"] = "LR"
+	buf := &strings.Builder{}
+	assert.NoError(t, graph.DotRenderer{}.Render(buf, model))
+	t.Log(buf.String())
+	assert.Equal(t, `digraph {
+	newrank = "true"
+	rankdir = "LR"
+		"leaf" [fillcolor="green" label="leaf" shape="box" style="filled" tooltip="leaf"]
+		"root" [fillcolor="green" label="root" shape="box" style="filled" tooltip="root"]
+
+		"root" -> "leaf" [color="black" style="solid" tooltip="root -> leaf"]
+
+}`, buf.String())
+
+	// json
+	buf.Reset()
+	assert.NoError(t, g.Render(buf, graph.JSONRenderer{}))
+	decoded := &graph.RenderGraph{}
+	assert.NoError(t, json.Unmarshal([]byte(buf.String()), decoded))
+	assert.Len(t, decoded.Nodes, 2)
+	assert.Equal(t, "leaf", decoded.Nodes[0].Name)
+	assert.Equal(t, "green", decoded.Nodes[0].Attributes["fillcolor"])
+	assert.Equal(t, []*graph.RenderEdge{{From: "root", To: "leaf", Attributes: map[string]string{"color": "black", "style": "solid", "tooltip": "root -> leaf"}}}, decoded.Edges)
+
+	// custom template
+	tmpl := template.Must(template.New("custom").Parse(`{{range .Nodes}}{{.Name}};{{end}}{{range .Edges}}{{.From}}->{{.To}};{{end}}`))
+	buf.Reset()
+	assert.NoError(t, g.Render(buf, graph.TemplateRenderer{Template: tmpl}))
+	assert.Equal(t, "leaf;root;root->leaf;", buf.String())
+
+	// node attributes override spec fields
+	g2 := graph.NewGraph(func(from, to *attributedNode) *graph.DotEdgeSpec {
+		return edgeSpecFromConnection(&from.testNode, &to.testNode)
+	})
+	g2.AddNode(&attributedNode{testNode{Name: "custom"}})
+	model = g2.ToRenderGraph()
+	assert.Equal(t, map[string]string{"label": "custom", "tooltip": "custom", "shape": "circle", "style": "filled", "fillcolor": "green", "penwidth": "2"}, model.Nodes[0].Attributes)
+}
+
+type attributedNode struct {
+	testNode
+}
+
+func (an *attributedNode) DotSpec() *graph.DotNodeSpec {
+	spec := an.testNode.DotSpec()
+	spec.Attributes = map[string]string{"shape": "circle", "penwidth": "2"}
+	return spec
+}
+
+func TestDeterministicOrder(t *testing.T) {
+	build := func(options ...graph.GraphOptionPreparer) *graph.Graph[*testNode] {
+		g := graph.NewGraph(edgeSpecFromConnection, options...)
+		root := &testNode{Name: "root"}
+		g.AddNode(root)
+		for _, name := range []string{"c", "a", "b"} {
+			n := &testNode{Name: name}
+			g.AddNode(n)
+			g.Connect(root, n)
+		}
+		summary := &testNode{Name: "summary"}
+		g.AddNode(summary)
+		for _, name := range []string{"b", "c", "a"} {
+			g.Connect(&testNode{Name: name}, summary)
+		}
+		return g
+	}
+	names := func(nodes []*testNode) []string {
+		result := make([]string, 0, len(nodes))
+		for _, n := range nodes {
+			result = append(result, n.Name)
+		}
+		return result
+	}
+
+	priority := map[string]int{"b": 2, "a": 1}
+	for _, testCase := range []struct {
+		name     string
+		options  []graph.GraphOptionPreparer
+		expected []string
+	}{
+		{"insertion", nil, []string{"root", "c", "a", "b", "summary"}},
+		{"name", []graph.GraphOptionPreparer{graph.WithOrderByName()}, []string{"root", "a", "b", "c", "summary"}},
+		{"priority", []graph.GraphOptionPreparer{graph.WithOrderByPriority(func(name string) int { return priority[name] })}, []string{"root", "b", "a", "c", "summary"}},
+	} {
+		g := build(testCase.options...)
+		assert.Equal(t, testCase.expected, names(g.TopologicalSort()), testCase.name)
+
+		// rendered output is same across runs
+		dotGraph, err := g.ToDotGraph()
+		assert.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			again, err := build(testCase.options...).ToDotGraph()
+			assert.NoError(t, err)
+			assert.Equal(t, dotGraph, again, testCase.name)
+		}
+	}
+
+	dotGraph, err := build().ToDotGraph()
+	assert.NoError(t, err)
+	assert.Less(t, strings.Index(dotGraph, `"root" -> "c"`), strings.Index(dotGraph, `"root" -> "a"`))
+
+	// nil priority falls back to insertion order, instead of panic in the middle of a sort.
+	for _, options := range [][]graph.GraphOptionPreparer{
+		{graph.WithOrderByPriority(nil)},
+		{nil},
+		{func(options *graph.GraphOptions) *graph.GraphOptions {
+			options.OrderRule = graph.OrderByPriority
+			return options
+		}},
+	} {
+		assert.Equal(t, []string{"root", "c", "a", "b", "summary"}, names(build(options...).TopologicalSort()))
+	}
+	assert.Less(t, strings.Index(dotGraph, `"c" -> "summary"`), strings.Index(dotGraph, `"a" -> "summary"`))
+}
+
+func TestGraphValidation(t *testing.T) {
+	g := graph.NewGraph(edgeSpecFromConnection)
+	a, b, c := &testNode{Name: "a"}, &testNode{Name: "b"}, &testNode{Name: "c"}
+	g.AddNode(a)
+	g.AddNode(b)
+	g.AddNode(c)
+	assert.NoError(t, g.Connect(a, b))
+	assert.NoError(t, g.Connect(b, c))
+	assert.NoError(t, g.Validate())
+
+	err := g.Connect(a, a)
+	assert.True(t, errors.Is(err, graph.ErrSelfLoop))
+
+	err = g.Connect(c, a)
+	assert.True(t, errors.Is(err, graph.ErrCycle))
+	assert.Contains(t, err.Error(), "c -> a -> b -> c")
+
+	// rejected edges are not added
+	assert.Equal(t, []string{"a", "b", "c"}, []string{g.TopologicalSort()[0].Name, g.TopologicalSort()[1].Name, g.TopologicalSort()[2].Name})
+
+	// duplicate edge is reported by Validate
+	assert.NoError(t, g.Connect(a, b))
+	err = g.Validate()
+	assert.True(t, errors.Is(err, graph.ErrDuplicateEdge))
+	assert.Contains(t, err.Error(), "node a is connected to b more than once")
+}
+
+func TestGraphQuery(t *testing.T) {
+	g := graph.NewGraph(edgeSpecFromConnection, graph.WithOrderByName())
+	root := &testNode{Name: "root"}
+	calc1 := &testNode{Name: "calc1"}
+	calc2 := &testNode{Name: "calc2"}
+	summary := &testNode{Name: "summary"}
+	orphan := &testNode{Name: "orphan"}
+	for _, n := range []*testNode{root, calc1, calc2, summary, orphan} {
+		assert.NoError(t, g.AddNode(n))
+	}
+	assert.NoError(t, g.Connect(root, calc1))
+	assert.NoError(t, g.Connect(root, calc2))
+	assert.NoError(t, g.Connect(calc1, summary))
+	assert.NoError(t, g.Connect(calc2, summary))
+
+	names := func(nodes []*testNode) []string {
+		result := make([]string, 0, len(nodes))
+		for _, n := range nodes {
+			result = append(result, n.Name)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"calc1", "calc2", "orphan", "root", "summary"}, names(g.Nodes()))
+	assert.Equal(t, []string{"orphan", "root"}, names(g.Roots()))
+	assert.Equal(t, []string{"orphan", "summary"}, names(g.Leaves()))
+
+	edges := g.Edges()
+	assert.Equal(t, 4, len(edges))
+	assert.Equal(t, "calc1", edges[0].From.Name)
+	assert.Equal(t, "summary", edges[0].To.Name)
+
+	ancestors, err := g.Ancestors(summary)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"calc1", "calc2", "root"}, names(ancestors))
+
+	descendants, err := g.Descendants(calc1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"summary"}, names(descendants))
+
+	descendants, err = g.Descendants(orphan)
+	assert.NoError(t, err)
+	assert.Empty(t, descendants)
+
+	inDegree, err := g.InDegree(summary)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, inDegree)
+	outDegree, err := g.OutDegree(root)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, outDegree)
+
+	_, err = g.Ancestors(&testNode{Name: "calc3"})
+	assert.True(t, errors.Is(err, graph.ErrNodeNotExists))
+	_, err = g.InDegree(&testNode{Name: "calc3"})
+	assert.True(t, errors.Is(err, graph.ErrNodeNotExists))
+}
+
+func TestLevels(t *testing.T) {
+	g := graph.NewGraph(edgeSpecFromConnection, graph.WithOrderByName())
+	root := &testNode{Name: "root"}
+	calc1 := &testNode{Name: "calc1"}
+	calc2 := &testNode{Name: "calc2"}
+	calc3 := &testNode{Name: "calc3"}
+	summary := &testNode{Name: "summary"}
+	for _, n := range []*testNode{root, calc2, calc1, calc3, summary} {
+		assert.NoError(t, g.AddNode(n))
+	}
+	assert.NoError(t, g.Connect(root, calc1))
+	assert.NoError(t, g.Connect(root, calc2))
+	assert.NoError(t, g.Connect(calc1, calc3))
+	assert.NoError(t, g.Connect(calc3, summary))
+	// longest path decides the level, not the shortest
+	assert.NoError(t, g.Connect(calc2, summary))
+
+	levels := make([][]string, 0)
+	for _, level := range g.Levels() {
+		names := make([]string, 0, len(level))
+		for _, n := range level {
+			names = append(names, n.Name)
+		}
+		levels = append(levels, names)
+	}
+	assert.Equal(t, [][]string{{"root"}, {"calc1", "calc2"}, {"calc3"}, {"summary"}}, levels)
+
+	dotGraph, err := g.ToDotGraph()
+	assert.NoError(t, err)
+	assert.NotContains(t, dotGraph, `rank = "same"`)
+
+	buf := &strings.Builder{}
+	assert.NoError(t, g.Render(buf, graph.DotRenderer{RankByLevel: true}))
+	assert.Contains(t, buf.String(), "\n\t{ rank = \"same\"; \"calc1\"; \"calc2\"; }\n")
+	assert.Contains(t, buf.String(), "\n\t{ rank = \"same\"; \"summary\"; }\n}")
+
+	// levels are computed by renderers needing them, same as Graph.Levels, or taken from the model if set.
+	model := g.ToRenderGraph()
+	assert.Empty(t, model.Levels)
+	buf.Reset()
+	assert.NoError(t, graph.JSONRenderer{}.Render(buf, model))
+	assert.NotContains(t, buf.String(), `"levels"`)
+	model.Levels = [][]string{{"root", "calc1", "calc2"}, {"calc3", "summary"}}
+	buf.Reset()
+	assert.NoError(t, graph.DotRenderer{RankByLevel: true}.Render(buf, model))
+	assert.Contains(t, buf.String(), "\n\t{ rank = \"same\"; \"calc3\"; \"summary\"; }\n}")
+}
+
+func TestTerminalRenderer(t *testing.T) {
+	g := graph.NewGraph(edgeSpecFromConnection, graph.WithOrderByName())
+	root := &testNode{Name: "root"}
+	calc1 := &testNode{Name: "calc1"}
+	calc2 := &testNode{Name: "calc2"}
+	summary := &testNode{Name: "summary"}
+	for _, n := range []*testNode{root, calc1, calc2, summary} {
+		assert.NoError(t, g.AddNode(n))
+	}
+	assert.NoError(t, g.Connect(root, calc1))
+	assert.NoError(t, g.Connect(root, calc2))
+	assert.NoError(t, g.Connect(calc1, summary))
+	assert.NoError(t, g.Connect(calc2, summary))
+
+	buf := &strings.Builder{}
+	assert.NoError(t, g.Render(buf, graph.TerminalRenderer{}))
+	t.Log("\n" + buf.String())
+	assert.Equal(t, `┌──────┐
+│ root │
+└──────┘
+  │
+  ▼
+┌────────┐ ┌────────┐
+│ calc1  │ │ calc2  │
+│ ← root │ │ ← root │
+└────────┘ └────────┘
+  │
+  ▼
+┌────────────────┐
+│ summary        │
+│ ← calc1, calc2 │
+└────────────────┘
+`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, g.Render(buf, graph.TerminalRenderer{Colors: true}))
+	// testNode fillcolor is green
+	assert.Contains(t, buf.String(), "\x1b[32m│ root │\x1b[0m")
+}
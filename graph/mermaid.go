@@ -0,0 +1,173 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// https://mermaid.js.org/syntax/flowchart.html
+
+// mermaidShapes maps DOT node shapes to Mermaid node shape delimiters, unknown shapes render as box.
+var mermaidShapes = map[string][2]string{
+	"box":           {"[", "]"},
+	"rect":          {"[", "]"},
+	"rectangle":     {"[", "]"},
+	"square":        {"[", "]"},
+	"ellipse":       {"([", "])"},
+	"oval":          {"([", "])"},
+	"circle":        {"((", "))"},
+	"doublecircle":  {"(((", ")))"},
+	"diamond":       {"{", "}"},
+	"hexagon":       {"{{", "}}"},
+	"triangle":      {"[/", "\\]"},
+	"parallelogram": {"[/", "/]"},
+	"trapezium":     {"[/", "\\]"},
+	"invtrapezium":  {"[\\", "/]"},
+	"cylinder":      {"[(", ")]"},
+}
+
+// MermaidRenderer renders Mermaid flowchart.
+//
+//	node shape maps to Mermaid node shape, node fillcolor and style map to classDef, edge color and style map to linkStyle.
+//	node ids are generated, so any node name is safe. graph attribute "direction" sets flowchart direction, TD by default.
+type MermaidRenderer struct{}
+
+func (MermaidRenderer) Render(w io.Writer, g *RenderGraph) error {
+	direction := g.Attributes["direction"]
+	if direction == "" {
+		direction = "TD"
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "flowchart %s\n", mermaidIdentifier(direction))
+
+	nodeIds := make(map[string]string, len(g.Nodes))
+	classDefs := make(map[string]string)
+	classNames := make([]string, 0)
+	for i, node := range g.Nodes {
+		nodeId := fmt.Sprintf("n%d", i)
+		nodeIds[node.Name] = nodeId
+
+		shape, ok := mermaidShapes[node.Attributes["shape"]]
+		if !ok {
+			shape = mermaidShapes["box"]
+		}
+		label := node.Attributes["label"]
+		if label == "" {
+			label = node.Name
+		}
+		fmt.Fprintf(sb, "\t%s%s\"%s\"%s", nodeId, shape[0], escapeMermaidLabel(label), shape[1])
+
+		if className, classDef := mermaidNodeClass(node.Attributes); className != "" {
+			if _, ok := classDefs[className]; !ok {
+				classDefs[className] = classDef
+				classNames = append(classNames, className)
+			}
+			fmt.Fprintf(sb, ":::%s", className)
+		}
+		sb.WriteString("\n")
+	}
+
+	for _, edge := range g.Edges {
+		fromId, ok := nodeIds[edge.From]
+		if !ok {
+			return NewGraphError(ErrConnectNotExistingNode, fmt.Sprintf("edge from node %s, it's not added in this graph", edge.From))
+		}
+		toId, ok := nodeIds[edge.To]
+		if !ok {
+			return NewGraphError(ErrConnectNotExistingNode, fmt.Sprintf("edge to node %s, it's not added in this graph", edge.To))
+		}
+		fmt.Fprintf(sb, "\t%s --> %s\n", fromId, toId)
+	}
+
+	sort.Strings(classNames)
+	for _, className := range classNames {
+		fmt.Fprintf(sb, "\tclassDef %s %s\n", className, classDefs[className])
+	}
+
+	for i, edge := range g.Edges {
+		if linkStyle := mermaidLinkStyle(edge.Attributes); linkStyle != "" {
+			fmt.Fprintf(sb, "\tlinkStyle %d %s\n", i, linkStyle)
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// ToMermaid renders the graph as Mermaid flowchart, see MermaidRenderer.
+func (g *Graph[NT]) ToMermaid() (string, error) {
+	return g.renderToString(MermaidRenderer{})
+}
+
+// mermaidNodeClass returns a class name and its classDef for the fill color and style of the node.
+func mermaidNodeClass(attributes map[string]string) (string, string) {
+	var properties []string
+	className := ""
+	style := attributes["style"]
+	if fillColor := attributes["fillcolor"]; fillColor != "" && strings.Contains(style, "filled") && dotColorRegex.MatchString(fillColor) {
+		properties = append(properties, "fill:"+fillColor)
+		className = mermaidIdentifier(fillColor)
+	}
+	if strings.Contains(style, "dashed") {
+		properties = append(properties, "stroke-dasharray:5 5")
+		className += "_dashed"
+	}
+	if strings.Contains(style, "bold") {
+		properties = append(properties, "stroke-width:3px")
+		className += "_bold"
+	}
+
+	if len(properties) == 0 {
+		return "", ""
+	}
+	return "s_" + strings.TrimPrefix(className, "_"), strings.Join(properties, ",")
+}
+
+func mermaidLinkStyle(attributes map[string]string) string {
+	var properties []string
+	style := attributes["style"]
+	if color := attributes["color"]; color != "" && dotColorRegex.MatchString(color) {
+		properties = append(properties, "stroke:"+color)
+	}
+	if strings.Contains(style, "bold") {
+		properties = append(properties, "stroke-width:2px")
+	}
+	if strings.Contains(style, "dashed") {
+		properties = append(properties, "stroke-dasharray:5 5")
+	}
+	if strings.Contains(style, "dotted") {
+		properties = append(properties, "stroke-dasharray:2 2")
+	}
+
+	return strings.Join(properties, ",")
+}
+
+// mermaidIdentifier keeps letters, digits and underscore, so a color like "#ff0000" can be part of a class name.
+func mermaidIdentifier(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// escapeMermaidLabel escapes a label inside double quotes with Mermaid entity codes.
+func escapeMermaidLabel(label string) string {
+	sb := &strings.Builder{}
+	for _, r := range label {
+		switch r {
+		case '"', '#', '&', '<', '>', '`', '\\':
+			fmt.Fprintf(sb, "#%d;", r)
+		case '\n':
+			sb.WriteString("<br>")
+		case '\r':
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
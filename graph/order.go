@@ -0,0 +1,144 @@
+package graph
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// OrderRule decides the order of nodes which are otherwise unordered,
+// like nodes ready at same time in TopologicalSort, or nodes and edges in rendered output.
+type OrderRule string
+
+const (
+	// OrderByInsertion orders nodes by the order they are added to the graph.
+	OrderByInsertion OrderRule = "Insertion"
+	// OrderByName orders nodes by name lexically.
+	OrderByName OrderRule = "Name"
+	// OrderByPriority orders nodes by GraphOptions.Priority, higher priority first, then by insertion.
+	OrderByPriority OrderRule = "Priority"
+)
+
+type GraphOptions struct {
+	OrderRule OrderRule
+	// Priority of a node by name, used by OrderByPriority.
+	Priority func(nodeName string) int
+}
+
+type GraphOptionPreparer func(*GraphOptions) *GraphOptions
+
+// WithOrderByName orders nodes lexically by name, instead of insertion order.
+func WithOrderByName() GraphOptionPreparer {
+	return func(options *GraphOptions) *GraphOptions {
+		options.OrderRule = OrderByName
+		return options
+	}
+}
+
+// WithOrderByPriority orders nodes by priority, higher priority first, nodes with same priority keep insertion order.
+//
+//	nil priority falls back to insertion order.
+func WithOrderByPriority(priority func(nodeName string) int) GraphOptionPreparer {
+	return func(options *GraphOptions) *GraphOptions {
+		options.OrderRule = OrderByPriority
+		options.Priority = priority
+		return options
+	}
+}
+
+// less tells whether node a goes before node b by the order rule.
+func (g *Graph[NT]) less(a, b string) bool {
+	switch g.options.OrderRule {
+	case OrderByName:
+		if a != b {
+			return a < b
+		}
+	case OrderByPriority:
+		if pa, pb := g.options.Priority(a), g.options.Priority(b); pa != pb {
+			return pa > pb
+		}
+	}
+
+	return g.nodeIndex[a] < g.nodeIndex[b]
+}
+
+// orderedNodes returns all nodes ordered by the order rule.
+func (g *Graph[NT]) orderedNodes() []NT {
+	nodes := append([]NT{}, g.nodeOrder...)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return g.less(nodes[i].GetName(), nodes[j].GetName())
+	})
+	return nodes
+}
+
+// orderedEdges returns all edges ordered by from node, then by to node.
+func (g *Graph[NT]) orderedEdges() []*Edge[NT] {
+	edges := make([]*Edge[NT], 0)
+	for _, node := range g.orderedNodes() {
+		nodeEdges := append([]*Edge[NT]{}, g.nodeEdges[node.GetName()]...)
+		sort.SliceStable(nodeEdges, func(i, j int) bool {
+			return g.less(nodeEdges[i].To.GetName(), nodeEdges[j].To.GetName())
+		})
+		edges = append(edges, nodeEdges...)
+	}
+	return edges
+}
+
+// TopologicalSort returns nodes with every node after all nodes connected to it (Kahn's algorithm),
+// nodes ready at same time are ordered by the order rule, so the result is stable.
+func (g *Graph[NT]) TopologicalSort() []NT {
+	inDegree := make(map[string]int, len(g.nodeOrder))
+	for _, nodeEdges := range g.nodeEdges {
+		for _, edge := range nodeEdges {
+			inDegree[edge.To.GetName()]++
+		}
+	}
+
+	ready := &nodeHeap[NT]{graph: g}
+	for _, node := range g.nodeOrder {
+		if inDegree[node.GetName()] == 0 {
+			heap.Push(ready, node)
+		}
+	}
+
+	sorted := make([]NT, 0, len(g.nodeOrder))
+	visited := make(map[string]bool, len(g.nodeOrder))
+	for ready.Len() > 0 {
+		node := heap.Pop(ready).(NT)
+		sorted = append(sorted, node)
+		visited[node.GetName()] = true
+		for _, edge := range g.nodeEdges[node.GetName()] {
+			toName := edge.To.GetName()
+			inDegree[toName]--
+			if inDegree[toName] == 0 {
+				heap.Push(ready, edge.To)
+			}
+		}
+	}
+
+	// nodes in a cycle never get ready, keep them at the end instead of losing them.
+	for _, node := range g.orderedNodes() {
+		if !visited[node.GetName()] {
+			sorted = append(sorted, node)
+		}
+	}
+
+	return sorted
+}
+
+// nodeHeap is a heap of nodes by the order rule of the graph.
+type nodeHeap[NT NodeConstrain] struct {
+	graph *Graph[NT]
+	nodes []NT
+}
+
+func (h *nodeHeap[NT]) Len() int { return len(h.nodes) }
+func (h *nodeHeap[NT]) Less(i, j int) bool {
+	return h.graph.less(h.nodes[i].GetName(), h.nodes[j].GetName())
+}
+func (h *nodeHeap[NT]) Swap(i, j int) { h.nodes[i], h.nodes[j] = h.nodes[j], h.nodes[i] }
+func (h *nodeHeap[NT]) Push(x any)    { h.nodes = append(h.nodes, x.(NT)) }
+func (h *nodeHeap[NT]) Pop() any {
+	last := h.nodes[len(h.nodes)-1]
+	h.nodes = h.nodes[:len(h.nodes)-1]
+	return last
+}
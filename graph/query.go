@@ -0,0 +1,138 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Nodes returns all nodes, ordered by the order rule.
+func (g *Graph[NT]) Nodes() []NT {
+	return g.orderedNodes()
+}
+
+// Edges returns all edges, ordered by from node, then by to node.
+func (g *Graph[NT]) Edges() []Edge[NT] {
+	edges := make([]Edge[NT], 0)
+	for _, edge := range g.orderedEdges() {
+		edges = append(edges, *edge)
+	}
+	return edges
+}
+
+// Roots returns nodes without incoming edges, ordered by the order rule.
+func (g *Graph[NT]) Roots() []NT {
+	roots := make([]NT, 0)
+	for _, node := range g.orderedNodes() {
+		if len(g.nodeInEdges[node.GetName()]) == 0 {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+// Leaves returns nodes without outgoing edges, ordered by the order rule.
+func (g *Graph[NT]) Leaves() []NT {
+	leaves := make([]NT, 0)
+	for _, node := range g.orderedNodes() {
+		if len(g.nodeEdges[node.GetName()]) == 0 {
+			leaves = append(leaves, node)
+		}
+	}
+	return leaves
+}
+
+// InDegree returns number of edges connected to the node.
+func (g *Graph[NT]) InDegree(n NT) (int, error) {
+	if err := g.checkNodeExists(n); err != nil {
+		return 0, err
+	}
+	return len(g.nodeInEdges[n.GetName()]), nil
+}
+
+// OutDegree returns number of edges connected from the node.
+func (g *Graph[NT]) OutDegree(n NT) (int, error) {
+	if err := g.checkNodeExists(n); err != nil {
+		return 0, err
+	}
+	return len(g.nodeEdges[n.GetName()]), nil
+}
+
+// Ancestors returns nodes the node can be reached from, the node itself excluded, ordered by the order rule.
+//
+//	like all steps feeding into a step.
+func (g *Graph[NT]) Ancestors(n NT) ([]NT, error) {
+	if err := g.checkNodeExists(n); err != nil {
+		return nil, err
+	}
+	return g.reachable(n.GetName(), g.nodeInEdges, func(edge *Edge[NT]) NT { return edge.From }), nil
+}
+
+// Descendants returns nodes reachable from the node, the node itself excluded, ordered by the order rule.
+//
+//	like all steps affected when a step failed.
+func (g *Graph[NT]) Descendants(n NT) ([]NT, error) {
+	if err := g.checkNodeExists(n); err != nil {
+		return nil, err
+	}
+	return g.reachable(n.GetName(), g.nodeEdges, func(edge *Edge[NT]) NT { return edge.To }), nil
+}
+
+func (g *Graph[NT]) checkNodeExists(n NT) error {
+	if _, ok := g.nodes[n.GetName()]; !ok {
+		return NewGraphError(ErrNodeNotExists, fmt.Sprintf("node %s is not in this graph", n.GetName()))
+	}
+	return nil
+}
+
+// reachable walks edges from the node with breadth first search, next decides which end of an edge to walk to.
+func (g *Graph[NT]) reachable(nodeKey string, edges map[string][]*Edge[NT], next func(*Edge[NT]) NT) []NT {
+	visited := map[string]bool{nodeKey: true}
+	queue := []string{nodeKey}
+	found := make([]NT, 0)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, edge := range edges[current] {
+			node := next(edge)
+			if visited[node.GetName()] {
+				continue
+			}
+			visited[node.GetName()] = true
+			found = append(found, node)
+			queue = append(queue, node.GetName())
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return g.less(found[i].GetName(), found[j].GetName())
+	})
+	return found
+}
+
+// Levels returns nodes grouped by longest path from the roots, nodes in same level don't depend on each other and can run in parallel.
+//
+//	roots are in the first level, nodes in each level are ordered by the order rule.
+func (g *Graph[NT]) Levels() [][]NT {
+	levelOf := make(map[string]int, len(g.nodes))
+	levels := make([][]NT, 0)
+	for _, node := range g.TopologicalSort() {
+		level := 0
+		for _, edge := range g.nodeInEdges[node.GetName()] {
+			if fromLevel, ok := levelOf[edge.From.GetName()]; ok && fromLevel+1 > level {
+				level = fromLevel + 1
+			}
+		}
+		levelOf[node.GetName()] = level
+		if level == len(levels) {
+			levels = append(levels, make([]NT, 0))
+		}
+		levels[level] = append(levels[level], node)
+	}
+
+	for _, level := range levels {
+		sort.SliceStable(level, func(i, j int) bool {
+			return g.less(level[i].GetName(), level[j].GetName())
+		})
+	}
+	return levels
+}
@@ -0,0 +1,198 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"text/template"
+)
+
+// RenderGraph is a renderer neutral model of a graph, with arbitrary attributes on graph, nodes and edges.
+//
+//	attributes from DotNodeSpec and DotEdgeSpec use graphviz attribute names: label, tooltip, shape, style, fillcolor, color.
+type RenderGraph struct {
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Nodes      []*RenderNode     `json:"nodes"`
+	Edges      []*RenderEdge     `json:"edges"`
+	// Levels are node names grouped like Graph.Levels, left empty by ToRenderGraph,
+	// renderers needing them (DotRenderer.RankByLevel, TerminalRenderer) compute them from nodes and edges.
+	Levels [][]string `json:"levels,omitempty"`
+	// Ranks are groups of node names placed on same rank by DotRenderer, empty unless DotRenderer.RankByLevel is set.
+	Ranks [][]string `json:"ranks,omitempty"`
+}
+
+// RenderNode is a node in RenderGraph.
+type RenderNode struct {
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// RenderEdge is an edge in RenderGraph.
+type RenderEdge struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Renderer writes a RenderGraph in some format.
+type Renderer interface {
+	Render(w io.Writer, g *RenderGraph) error
+}
+
+// TemplateRenderer renders the RenderGraph with a text/template, template is executed with *RenderGraph.
+type TemplateRenderer struct {
+	Template *template.Template
+}
+
+func (r TemplateRenderer) Render(w io.Writer, g *RenderGraph) error {
+	return r.Template.Execute(w, g)
+}
+
+// DotRenderer renders graphviz DOT.
+type DotRenderer struct {
+	// RankByLevel places nodes of same level on same rank (rank=same subgraphs), so parallel nodes line up.
+	RankByLevel bool
+}
+
+func (r DotRenderer) Render(w io.Writer, g *RenderGraph) error {
+	if r.RankByLevel {
+		ranked := *g
+		ranked.Ranks = g.levels()
+		g = &ranked
+	}
+	return TemplateRenderer{Template: digraphTemplate}.Render(w, g)
+}
+
+// JSONRenderer renders the RenderGraph as JSON, nodes plus edges plus attributes.
+type JSONRenderer struct {
+	// Indent of nested elements, no indent if empty.
+	Indent string
+}
+
+func (r JSONRenderer) Render(w io.Writer, g *RenderGraph) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", r.Indent)
+	return encoder.Encode(g)
+}
+
+// ToRenderGraph returns the renderer neutral model of the graph, nodes and edges are ordered by the OrderRule of the graph.
+//
+//	graph attributes can be added to the returned model before rendering, like rankdir or a legend for a custom template.
+func (g *Graph[NT]) ToRenderGraph() *RenderGraph {
+	rg := &RenderGraph{
+		Attributes: map[string]string{},
+		Nodes:      make([]*RenderNode, 0, len(g.nodes)),
+		Edges:      make([]*RenderEdge, 0),
+	}
+
+	for _, node := range g.orderedNodes() {
+		rg.Nodes = append(rg.Nodes, node.DotSpec().renderNode())
+	}
+
+	for _, edge := range g.orderedEdges() {
+		rg.Edges = append(rg.Edges, g.edgeSpecFunc(edge.From, edge.To).renderEdge())
+	}
+
+	return rg
+}
+
+// levels returns Levels if set, otherwise node names grouped by longest path from the roots,
+// nodes in each level keep the order of Nodes, which follows the order rule of the graph.
+func (rg *RenderGraph) levels() [][]string {
+	if len(rg.Levels) > 0 {
+		return rg.Levels
+	}
+
+	incoming := make(map[string][]string)
+	for _, edge := range rg.Edges {
+		incoming[edge.To] = append(incoming[edge.To], edge.From)
+	}
+
+	levelOf := make(map[string]int, len(rg.Nodes))
+	visiting := make(map[string]bool)
+	var levelOfNode func(name string) int
+	levelOfNode = func(name string) int {
+		if level, ok := levelOf[name]; ok {
+			return level
+		}
+		if visiting[name] {
+			// a cycle, graph is rendered anyway.
+			return 0
+		}
+		visiting[name] = true
+		level := 0
+		for _, from := range incoming[name] {
+			level = max(level, levelOfNode(from)+1)
+		}
+		levelOf[name] = level
+		return level
+	}
+
+	levels := make([][]string, 0)
+	for _, node := range rg.Nodes {
+		level := levelOfNode(node.Name)
+		for len(levels) <= level {
+			levels = append(levels, make([]string, 0))
+		}
+		levels[level] = append(levels[level], node.Name)
+	}
+
+	// levels of nodes only referenced by edges can be empty, drop them.
+	nonEmpty := levels[:0]
+	for _, level := range levels {
+		if len(level) > 0 {
+			nonEmpty = append(nonEmpty, level)
+		}
+	}
+	return nonEmpty
+}
+
+// Render the graph with given renderer.
+func (g *Graph[NT]) Render(w io.Writer, renderer Renderer) error {
+	return renderer.Render(w, g.ToRenderGraph())
+}
+
+func (g *Graph[NT]) renderToString(renderer Renderer) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := g.Render(buf, renderer); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (spec *DotNodeSpec) renderNode() *RenderNode {
+	attributes := newAttributes(spec.Attributes, map[string]string{
+		"label":     spec.DisplayName,
+		"tooltip":   spec.Tooltip,
+		"shape":     spec.Shape,
+		"style":     spec.Style,
+		"fillcolor": spec.FillColor,
+	})
+
+	return &RenderNode{Name: spec.Name, Attributes: attributes}
+}
+
+func (spec *DotEdgeSpec) renderEdge() *RenderEdge {
+	attributes := newAttributes(spec.Attributes, map[string]string{
+		"tooltip": spec.Tooltip,
+		"style":   spec.Style,
+		"color":   spec.Color,
+	})
+
+	return &RenderEdge{From: spec.FromNodeName, To: spec.ToNodeName, Attributes: attributes}
+}
+
+// newAttributes merges extra attributes over the known ones, empty values are dropped.
+func newAttributes(extra map[string]string, known map[string]string) map[string]string {
+	attributes := make(map[string]string, len(known)+len(extra))
+	for _, source := range []map[string]string{known, extra} {
+		for key, value := range source {
+			if value == "" {
+				delete(attributes, key)
+				continue
+			}
+			attributes[key] = value
+		}
+	}
+	return attributes
+}
@@ -7,17 +7,19 @@ import (
 // https://www.graphviz.org/docs/
 // http://magjac.com/graphviz-visual-editor/
 
-var digraphTemplate = template.Must(template.New("digraph").Parse(digraphTemplateText))
-
-type templateRef struct {
-	Nodes []*DotNodeSpec
-	Edges []*DotEdgeSpec
-}
+var digraphTemplate = template.Must(template.New("digraph").Funcs(DotFuncs).Parse(digraphTemplateText))
 
+// digraphTemplateText is the template used by DotRenderer, executed with *RenderGraph.
 const digraphTemplateText = `digraph {
 	newrank = "true"
-{{ range $node := $.Nodes}}		"{{$node.Name}}" [label="{{$node.DisplayName}}" shape={{$node.Shape}} style={{$node.Style}} tooltip="{{$node.Tooltip}}" fillcolor={{$node.FillColor}}] 
-{{ end }}        
-{{ range $edge := $.Edges}}		"{{$edge.FromNodeName}}" -> "{{$edge.ToNodeName}}" [style={{$edge.Style}} tooltip="{{$edge.Tooltip}}" color={{$edge.Color}}] 
+{{- range $key, $value := $.Attributes}}
+	{{dotAttribute $key $value}}
+{{- end}}
+{{ range $node := $.Nodes}}		{{dotString $node.Name}} [{{dotAttributes $node.Attributes}}]
+{{ end }}
+{{ range $edge := $.Edges}}		{{dotString $edge.From}} -> {{dotString $edge.To}} [{{dotAttributes $edge.Attributes}}]
 {{ end }}
+{{- range $rank := $.Ranks}}
+	{ rank = "same";{{range $name := $rank}} {{dotString $name}};{{end}} }
+{{- end}}
 }`
@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ansiColors maps graphviz color names to ANSI SGR codes, colors not listed are rendered without color.
+var ansiColors = map[string]string{
+	"black":     "30",
+	"red":       "31",
+	"green":     "32",
+	"yellow":    "33",
+	"blue":      "34",
+	"purple":    "35",
+	"magenta":   "35",
+	"cyan":      "36",
+	"lightblue": "36",
+	"white":     "37",
+	"pink":      "95",
+	"gray":      "90",
+	"grey":      "90",
+	"orange":    "38;5;208",
+}
+
+// TerminalRenderer renders a DAG as box-drawing text for terminals and test logs, one row of boxes per level.
+//
+//	each box shows the label of the node, and the nodes it depends on.
+//	with Colors, boxes are colored by node fillcolor (or color) using ANSI escape codes.
+type TerminalRenderer struct {
+	Colors bool
+}
+
+func (r TerminalRenderer) Render(w io.Writer, g *RenderGraph) error {
+	nodes := make(map[string]*RenderNode, len(g.Nodes))
+	for _, node := range g.Nodes {
+		nodes[node.Name] = node
+	}
+	dependsOn := make(map[string][]string)
+	for _, edge := range g.Edges {
+		dependsOn[edge.To] = append(dependsOn[edge.To], terminalLabel(nil, edge.From))
+	}
+
+	sb := &strings.Builder{}
+	for i, level := range g.levels() {
+		if i > 0 {
+			sb.WriteString("  │\n  ▼\n")
+		}
+		boxes := make([][]string, 0, len(level))
+		colors := make([]string, 0, len(level))
+		for _, name := range level {
+			lines := []string{terminalLabel(nodes[name], name)}
+			if len(dependsOn[name]) > 0 {
+				lines = append(lines, "← "+strings.Join(dependsOn[name], ", "))
+			}
+			boxes = append(boxes, terminalBox(lines))
+			colors = append(colors, r.ansiColor(nodes[name]))
+		}
+
+		height := 0
+		for _, box := range boxes {
+			height = max(height, len(box))
+		}
+		for row := 0; row < height; row++ {
+			for j, box := range boxes {
+				if j > 0 {
+					sb.WriteByte(' ')
+				}
+				line := box[min(row, len(box)-1)]
+				if row >= len(box) {
+					line = strings.Repeat(" ", utf8.RuneCountInString(line))
+				}
+				if colors[j] != "" && row < len(box) {
+					line = fmt.Sprintf("\x1b[%sm%s\x1b[0m", colors[j], line)
+				}
+				sb.WriteString(line)
+			}
+			sb.WriteByte('\n')
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (r TerminalRenderer) ansiColor(node *RenderNode) string {
+	if !r.Colors || node == nil {
+		return ""
+	}
+	if color, ok := ansiColors[node.Attributes["fillcolor"]]; ok {
+		return color
+	}
+	return ansiColors[node.Attributes["color"]]
+}
+
+// terminalLabel is the label of the node on a single line, falls back to node name.
+func terminalLabel(node *RenderNode, name string) string {
+	label := name
+	if node != nil && node.Attributes["label"] != "" {
+		label = node.Attributes["label"]
+	}
+	return strings.Join(strings.Fields(strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			// control characters, including ANSI escape, would mess up the terminal.
+			return ' '
+		}
+		return r
+	}, label)), " ")
+}
+
+// terminalBox draws lines in a box, all lines of the box have same width.
+func terminalBox(lines []string) []string {
+	width := 0
+	for _, line := range lines {
+		width = max(width, utf8.RuneCountInString(line))
+	}
+
+	box := make([]string, 0, len(lines)+2)
+	box = append(box, "┌"+strings.Repeat("─", width+2)+"┐")
+	for _, line := range lines {
+		box = append(box, "│ "+line+strings.Repeat(" ", width-utf8.RuneCountInString(line))+" │")
+	}
+	box = append(box, "└"+strings.Repeat("─", width+2)+"┘")
+	return box
+}
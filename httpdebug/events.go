@@ -0,0 +1,116 @@
+package httpdebug
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Azure/go-asyncjob"
+)
+
+// eventBufferSize of the subscription per stream, oldest events are dropped if client is not keeping up,
+// client gets a full snapshot when job completed anyway.
+const eventBufferSize = 256
+
+// streamEvent is the data of a Server-Sent Event, for a asyncjob.JobEvent.
+type streamEvent struct {
+	Type          asyncjob.JobEventType `json:"type"`
+	JobInstanceId string                `json:"jobInstanceId"`
+	StepName      string                `json:"stepName,omitempty"`
+	Timestamp     time.Time             `json:"timestamp"`
+	// State of the step after the event, or state of the job for EventJobCompleted.
+	State   string `json:"state"`
+	Retries uint   `json:"retries"`
+	Error   string `json:"error,omitempty"`
+}
+
+func newStreamEvent(ji asyncjob.JobInstanceMeta, event asyncjob.JobEvent) *streamEvent {
+	se := &streamEvent{
+		Type:          event.Type,
+		JobInstanceId: event.JobInstanceId,
+		StepName:      event.StepName,
+		Timestamp:     event.Timestamp,
+	}
+
+	switch event.Type {
+	case asyncjob.EventStepStarted, asyncjob.EventStepRetried:
+		se.State = string(asyncjob.StepStateRunning)
+	case asyncjob.EventStepCompleted:
+		se.State = string(asyncjob.StepStateCompleted)
+	case asyncjob.EventStepFailed:
+		se.State = string(asyncjob.StepStateFailed)
+	case asyncjob.EventStepBlocked:
+		se.State = string(asyncjob.StepStateBlocked)
+	case asyncjob.EventJobCompleted:
+		se.State = string(ji.GetState())
+	}
+
+	if event.ExecutionData.Retried != nil {
+		se.Retries = event.ExecutionData.Retried.Count
+	}
+	if event.Error != nil {
+		se.Error = event.Error.Error()
+	}
+
+	return se
+}
+
+// serveEvents streams state transitions of a job instance as Server-Sent Events.
+//
+//	first event is "snapshot" with current JobInstanceSnapshot, followed by events named by asyncjob.JobEventType,
+//	stream ends after JobCompleted.
+func (h *handler) serveEvents(w http.ResponseWriter, r *http.Request, jobId string) {
+	ji, ok := h.runner.Get(jobId)
+	if !ok {
+		writeError(w, http.StatusNotFound, asyncjob.ErrJobInstanceNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	// subscribe before snapshot, so no transition is missed in between.
+	subscription := ji.Subscribe(asyncjob.WithEventBufferSize(eventBufferSize), asyncjob.WithEventOverflowPolicy(asyncjob.EventOverflowDropOldest))
+	defer subscription.Unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeServerSentEvent(w, "snapshot", ji.Snapshot()); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return
+			}
+			if err := writeServerSentEvent(w, string(event.Type), newStreamEvent(ji, event)); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, name string, data interface{}) error {
+	// json encoding has no newline, fit in a single data line.
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, dataJson)
+	return err
+}
@@ -0,0 +1,259 @@
+// Package httpdebug serves job instances tracked by an asyncjob.JobRunner over HTTP,
+// for debugging and simple administration.
+//
+//	mount the handler with a prefix, for example:
+//	mux.Handle("/debug/asyncjob/", http.StripPrefix("/debug/asyncjob", httpdebug.NewHandler(runner)))
+//
+// Routes, relative to the mount point:
+//
+//	GET  /                 self-contained HTML page, renders the DAG of a job instance in browser
+//	GET  /jobs             JSON list of job instance snapshots, filter by ?definition=name&state=running
+//	GET  /jobs/{id}        JSON snapshot of a job instance
+//	GET  /jobs/{id}/dot    DOT graph of a job instance
+//	GET  /jobs/{id}/events Server-Sent Events of step state transitions of a job instance
+//	POST /jobs/{id}/cancel cancel a job instance
+//	POST /jobs/{id}/retry  retry a finished job instance, responds snapshot of the new job instance
+//
+// {id} is path escaped, so job ids containing '/' work. POST requests need the ActionHeader header,
+// a cross-site form or simple request can't set it, which protects the actions from CSRF.
+package httpdebug
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/go-asyncjob"
+)
+
+// ActionHeader is required on POST requests (any non-empty value), browsers only send it cross-site after a CORS preflight.
+const ActionHeader = "X-Asyncjob-Action"
+
+//go:embed index.html
+var indexTemplate []byte
+
+// indexPage has step state colors injected, so the page shares color scheme with DOT from JobInstance.Visualize,
+// and graph layout injected, so the page draws the graph same as JobInstance.WriteHTMLReport.
+var indexPage = bytes.Replace(bytes.Replace(indexTemplate, []byte("/*STATE_COLORS*/{}"), stateColorsJson(), 1), []byte("/*GRAPH_LAYOUT*/{}"), graphLayoutJson(), 1)
+
+func stateColorsJson() []byte {
+	colors := map[asyncjob.StepState]string{}
+	for _, state := range []asyncjob.StepState{asyncjob.StepStatePending, asyncjob.StepStateRunning, asyncjob.StepStateCompleted, asyncjob.StepStateFailed, asyncjob.StepStateBlocked} {
+		colors[state] = state.Color()
+	}
+
+	colorsJson, _ := json.Marshal(colors)
+	return colorsJson
+}
+
+func graphLayoutJson() []byte {
+	layoutJson, _ := json.Marshal(asyncjob.DefaultGraphLayout())
+	return layoutJson
+}
+
+type HandlerOptions struct {
+	// ReadOnly disables cancel and retry actions.
+	ReadOnly bool
+}
+
+type HandlerOptionPreparer func(*HandlerOptions) *HandlerOptions
+
+// WithReadOnly disables cancel and retry actions of the handler.
+func WithReadOnly() HandlerOptionPreparer {
+	return func(options *HandlerOptions) *HandlerOptions {
+		options.ReadOnly = true
+		return options
+	}
+}
+
+type handler struct {
+	runner  *asyncjob.JobRunner
+	options *HandlerOptions
+}
+
+// NewHandler returns a http.Handler serving job instances tracked by the runner.
+func NewHandler(runner *asyncjob.JobRunner, optionDecorators ...HandlerOptionPreparer) http.Handler {
+	h := &handler{
+		runner:  runner,
+		options: &HandlerOptions{},
+	}
+
+	for _, decorator := range optionDecorators {
+		h.options = decorator(h.options)
+	}
+
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// route on escaped path, so an escaped '/' in job id doesn't split the segment.
+	path := strings.Trim(r.URL.EscapedPath(), "/")
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		segments[i] = unescaped
+	}
+
+	switch {
+	case path == "":
+		h.serveIndex(w, r)
+	case path == "jobs":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		h.serveList(w, r)
+	case segments[0] == "jobs" && len(segments) == 2:
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		h.serveSnapshot(w, segments[1])
+	case segments[0] == "jobs" && len(segments) == 3:
+		h.serveJobAction(w, r, segments[1], segments[2])
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (h *handler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	// page uses relative links, it needs to be served with trailing slash.
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		requestPath, _, _ := strings.Cut(r.RequestURI, "?")
+		http.Redirect(w, r, requestPath+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(indexPage)
+}
+
+func (h *handler) serveList(w http.ResponseWriter, r *http.Request) {
+	filter := &asyncjob.JobInstanceFilter{
+		DefinitionName: r.URL.Query().Get("definition"),
+	}
+	for _, state := range r.URL.Query()["state"] {
+		filter.States = append(filter.States, asyncjob.JobState(state))
+	}
+
+	snapshots := make([]*asyncjob.JobInstanceSnapshot, 0)
+	for _, ji := range h.runner.List(filter) {
+		snapshots = append(snapshots, ji.Snapshot())
+	}
+
+	writeJSON(w, http.StatusOK, snapshots)
+}
+
+func (h *handler) serveSnapshot(w http.ResponseWriter, jobId string) {
+	ji, ok := h.runner.Get(jobId)
+	if !ok {
+		writeError(w, http.StatusNotFound, asyncjob.ErrJobInstanceNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ji.Snapshot())
+}
+
+func (h *handler) serveJobAction(w http.ResponseWriter, r *http.Request, jobId, action string) {
+	switch action {
+	case "dot":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		ji, ok := h.runner.Get(jobId)
+		if !ok {
+			writeError(w, http.StatusNotFound, asyncjob.ErrJobInstanceNotFound)
+			return
+		}
+		dotGraph, err := ji.Visualize()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		_, _ = w.Write([]byte(dotGraph))
+	case "events":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		h.serveEvents(w, r, jobId)
+	case "cancel":
+		if !allowMethod(w, r, http.MethodPost) || !h.allowAction(w, r) {
+			return
+		}
+		if err := h.runner.Cancel(jobId); err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case "retry":
+		if !allowMethod(w, r, http.MethodPost) || !h.allowAction(w, r) {
+			return
+		}
+		// retried job instance outlives the request.
+		ji, err := h.runner.Retry(context.WithoutCancel(r.Context()), jobId)
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, ji.Snapshot())
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (h *handler) allowAction(w http.ResponseWriter, r *http.Request) bool {
+	if h.options.ReadOnly {
+		writeError(w, http.StatusForbidden, errors.New("handler is read-only"))
+		return false
+	}
+	if r.Header.Get(ActionHeader) == "" {
+		writeError(w, http.StatusForbidden, errors.New("missing "+ActionHeader+" header"))
+		return false
+	}
+	return true
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return false
+	}
+	return true
+}
+
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, asyncjob.ErrJobInstanceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, asyncjob.ErrJobInstanceNotFinished),
+		errors.Is(err, asyncjob.ErrConcurrencyKeyConflict),
+		errors.Is(err, asyncjob.ErrJobInstanceIdConflict),
+		errors.Is(err, asyncjob.ErrDefinitionChanged):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,225 @@
+package httpdebug_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-asyncjob"
+	"github.com/Azure/go-asyncjob/httpdebug"
+	"github.com/Azure/go-asynctask"
+	"github.com/stretchr/testify/assert"
+)
+
+func newGatedJob(t *testing.T, name string) *asyncjob.JobDefinition[chan struct{}] {
+	job := asyncjob.NewJobDefinition[chan struct{}](name)
+	_, err := asyncjob.AddStep(job, "WaitForGate", func(gate chan struct{}) asynctask.AsyncFunc[interface{}] {
+		return func(ctx context.Context) (interface{}, error) {
+			select {
+			case <-gate:
+				return nil, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	})
+	assert.NoError(t, err)
+	return job
+}
+
+func doRequest(t *testing.T, server *httptest.Server, method, path string) (*http.Response, string) {
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	assert.NoError(t, err)
+	if method == http.MethodPost {
+		req.Header.Set(httpdebug.ActionHeader, "1")
+	}
+	resp, err := server.Client().Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	runner := asyncjob.NewJobRunner()
+	job := newGatedJob(t, "gatedJob")
+	assert.NoError(t, runner.Register(job))
+
+	gate := make(chan struct{})
+	finished, err := asyncjob.StartJob(context.Background(), runner, job, gate, asyncjob.WithJobId("finished"))
+	assert.NoError(t, err)
+	close(gate)
+	assert.NoError(t, finished.Wait(context.Background()))
+	running, err := asyncjob.StartJob(context.Background(), runner, job, make(chan struct{}), asyncjob.WithJobId("running"))
+	assert.NoError(t, err)
+	slashed, err := asyncjob.StartJob(context.Background(), runner, job, make(chan struct{}), asyncjob.WithJobId("tenant/job 1%"))
+	assert.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/asyncjob/", http.StripPrefix("/debug/asyncjob", httpdebug.NewHandler(runner)))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// html page
+	resp, body := doRequest(t, server, http.MethodGet, "/debug/asyncjob/")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+	assert.Contains(t, body, "<svg")
+	assert.NotContains(t, body, "<script src")
+
+	// list
+	resp, body = doRequest(t, server, http.MethodGet, "/debug/asyncjob/jobs")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var snapshots []*asyncjob.JobInstanceSnapshot
+	assert.NoError(t, json.Unmarshal([]byte(body), &snapshots))
+	assert.Len(t, snapshots, 3)
+
+	_, body = doRequest(t, server, http.MethodGet, "/debug/asyncjob/jobs?state=running")
+	assert.NoError(t, json.Unmarshal([]byte(body), &snapshots))
+	assert.Len(t, snapshots, 2)
+
+	// job id with '/' is escaped in path
+	resp, body = doRequest(t, server, http.MethodGet, "/debug/asyncjob/jobs/"+url.PathEscape("tenant/job 1%"))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"jobInstanceId":"tenant/job 1%"`)
+	resp, _ = doRequest(t, server, http.MethodPost, "/debug/asyncjob/jobs/tenant%2Fjob%201%25/cancel")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	<-slashed.Done()
+	resp, _ = doRequest(t, server, http.MethodGet, "/debug/asyncjob/jobs/tenant/job/dot")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// actions without ActionHeader are refused
+	resp, err = server.Client().Post(server.URL+"/debug/asyncjob/jobs/running/cancel", "application/x-www-form-urlencoded", nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, asyncjob.JobStateRunning, running.GetState())
+
+	// snapshot and dot
+	resp, body = doRequest(t, server, http.MethodGet, "/debug/asyncjob/jobs/finished")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	snapshot := &asyncjob.JobInstanceSnapshot{}
+	assert.NoError(t, json.Unmarshal([]byte(body), snapshot))
+	assert.Equal(t, asyncjob.JobStateCompleted, snapshot.State)
+	assert.Len(t, snapshot.Steps, 2)
+
+	resp, body = doRequest(t, server, http.MethodGet, "/debug/asyncjob/jobs/finished/dot")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(body, "digraph"))
+
+	resp, _ = doRequest(t, server, http.MethodGet, "/debug/asyncjob/jobs/notExists")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = doRequest(t, server, http.MethodGet, "/debug/asyncjob/jobs/running/cancel")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	// retry a running job is refused
+	resp, _ = doRequest(t, server, http.MethodPost, "/debug/asyncjob/jobs/running/retry")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// cancel
+	resp, _ = doRequest(t, server, http.MethodPost, "/debug/asyncjob/jobs/running/cancel")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	<-running.Done()
+	assert.Equal(t, asyncjob.JobStateCanceled, running.GetState())
+
+	// retry without StateStore starts a new job instance
+	resp, body = doRequest(t, server, http.MethodPost, "/debug/asyncjob/jobs/running/retry")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.NoError(t, json.Unmarshal([]byte(body), snapshot))
+	assert.NotEqual(t, "running", snapshot.JobInstanceId)
+	retried, ok := runner.Get(snapshot.JobInstanceId)
+	assert.True(t, ok)
+	retried.Cancel()
+	<-retried.Done()
+
+	// read-only
+	readOnly := httptest.NewServer(httpdebug.NewHandler(runner, httpdebug.WithReadOnly()))
+	defer readOnly.Close()
+	resp, _ = doRequest(t, readOnly, http.MethodPost, "/jobs/finished/retry")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = doRequest(t, readOnly, http.MethodGet, "/jobs/finished")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestHandlerEvents(t *testing.T) {
+	t.Parallel()
+
+	runner := asyncjob.NewJobRunner()
+	job := newGatedJob(t, "gatedJob")
+	assert.NoError(t, runner.Register(job))
+
+	gate := make(chan struct{})
+	_, err := asyncjob.StartJob(context.Background(), runner, job, gate, asyncjob.WithJobId("streamed"))
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(httpdebug.NewHandler(runner))
+	defer server.Close()
+
+	// page shares color scheme with DotSpec
+	_, body := doRequest(t, server, http.MethodGet, "/")
+	assert.Contains(t, body, `"running":"`+asyncjob.StepStateRunning.Color()+`"`)
+	assert.NotContains(t, body, "/*STATE_COLORS*/")
+	// and graph layout with WriteHTMLReport
+	assert.Contains(t, body, fmt.Sprintf(`"nodeWidth":%d`, asyncjob.DefaultGraphLayout().NodeWidth))
+	assert.NotContains(t, body, "/*GRAPH_LAYOUT*/")
+
+	resp, err := server.Client().Get(server.URL + "/jobs/streamed/events")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() (string, string) {
+		var name, data string
+		for {
+			line, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "":
+				return name, data
+			}
+		}
+	}
+
+	name, data := readEvent()
+	assert.Equal(t, "snapshot", name)
+	snapshot := &asyncjob.JobInstanceSnapshot{}
+	assert.NoError(t, json.Unmarshal([]byte(data), snapshot))
+	assert.Equal(t, "streamed", snapshot.JobInstanceId)
+
+	close(gate)
+	states := map[string]string{}
+	for {
+		name, data = readEvent()
+		event := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal([]byte(data), &event))
+		if name == string(asyncjob.EventJobCompleted) {
+			assert.Equal(t, string(asyncjob.JobStateCompleted), event["state"])
+			break
+		}
+		states[event["stepName"].(string)] = event["state"].(string)
+	}
+	assert.Equal(t, string(asyncjob.StepStateCompleted), states["WaitForGate"])
+
+	// stream ends after job completed
+	_, err = reader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+
+	resp, _ = doRequest(t, server, http.MethodGet, "/jobs/notExists/events")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -2,8 +2,11 @@ package asyncjob
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
 
 	"github.com/Azure/go-asyncjob/graph"
 )
@@ -14,7 +17,11 @@ type JobDefinitionMeta interface {
 	GetStep(stepName string) (StepDefinitionMeta, bool) // TODO: switch bool to error
 	Seal()
 	Sealed() bool
+	Fingerprint() *DefinitionFingerprint
 	Visualize() (string, error)
+	VisualizeAs(format VisualizeFormat) (string, error)
+	Render(w io.Writer, renderer graph.Renderer) error
+	Explain() string
 
 	// not exposing for now.
 	addStep(step StepDefinitionMeta, precedingSteps ...StepDefinitionMeta) error
@@ -23,22 +30,46 @@ type JobDefinitionMeta interface {
 
 // JobDefinition defines a job with child steps, and step is organized in a Directed Acyclic Graph (DAG).
 type JobDefinition[T any] struct {
-	name string
+	name    string
+	options *JobDefinitionOptions
 
-	sealed   bool
-	steps    map[string]StepDefinitionMeta
-	stepsDag *graph.Graph[StepDefinitionMeta]
-	rootStep *StepDefinition[T]
+	sealed      bool
+	fingerprint *DefinitionFingerprint
+	steps       map[string]StepDefinitionMeta
+	stepsDag    *graph.Graph[StepDefinitionMeta]
+	rootStep    *StepDefinition[T]
+}
+
+type JobDefinitionOptions struct {
+	// StepOrder decides the order of steps otherwise unordered, like steps ready at same time, or steps in rendered output.
+	StepOrder []graph.GraphOptionPreparer
+}
+
+type JobDefinitionOptionPreparer func(*JobDefinitionOptions) *JobDefinitionOptions
+
+// WithStepOrder orders steps by the order rule, like graph.WithOrderByName() or graph.WithOrderByPriority(...),
+// steps are ordered by the order they are added by default. it applies to job instances of the definition too.
+func WithStepOrder(order graph.GraphOptionPreparer) JobDefinitionOptionPreparer {
+	return func(options *JobDefinitionOptions) *JobDefinitionOptions {
+		options.StepOrder = append(options.StepOrder, order)
+		return options
+	}
 }
 
 // Create new JobDefinition
 //
 //	it is suggest to build jobDefinition statically on process start, and reuse it for each job instance.
-func NewJobDefinition[T any](name string) *JobDefinition[T] {
+func NewJobDefinition[T any](name string, optionDecorators ...JobDefinitionOptionPreparer) *JobDefinition[T] {
+	options := &JobDefinitionOptions{}
+	for _, decorator := range optionDecorators {
+		options = decorator(options)
+	}
+
 	j := &JobDefinition[T]{
 		name:     name,
+		options:  options,
 		steps:    make(map[string]StepDefinitionMeta),
-		stepsDag: graph.NewGraph(connectStepDefinition),
+		stepsDag: graph.NewGraph(connectStepDefinition, options.StepOrder...),
 	}
 
 	rootStep := newStepDefinition[T](name, stepTypeRoot)
@@ -54,17 +85,94 @@ func NewJobDefinition[T any](name string) *JobDefinition[T] {
 //
 //	this will create and return new instance of the job
 //	caller will then be able to wait for the job instance
+//	if the job instance failed to construct, the error is returned by Wait, use StartE to get it directly.
 func (jd *JobDefinition[T]) Start(ctx context.Context, input T, jobOptions ...JobOptionPreparer) *JobInstance[T] {
 	if !jd.Sealed() {
 		jd.Seal()
 	}
 
 	ji := newJobInstance(jd, input, jobOptions...)
-	ji.start(ctx)
+	// error is reported by the job instance as well.
+	_ = ji.start(ctx)
 
 	return ji
 }
 
+// StartE is same as Start, but returns error if the job instance failed to construct.
+//
+//	error is a MessageError, like ErrSubscriptionAttached, or ErrRuntimeStepNotFound and ErrRuntimeStepTypeMismatch from a bug in asyncjob,
+//	steps already started are canceled.
+func (jd *JobDefinition[T]) StartE(ctx context.Context, input T, jobOptions ...JobOptionPreparer) (*JobInstance[T], error) {
+	if !jd.Sealed() {
+		jd.Seal()
+	}
+
+	ji := newJobInstance(jd, input, jobOptions...)
+	if err := ji.start(ctx); err != nil {
+		return nil, err
+	}
+
+	return ji, nil
+}
+
+// Resume a job instance persisted with WithStateStore.
+//
+//	steps completed in previous run are restored from the StateStore instead of executed again,
+//	rest of the steps are executed as usual. input should be same as previous run.
+//	if output of a step failed to decode, JobError with ErrRestoreStepState is returned, instead of executing the step again.
+//	if the definition changed since the state was persisted, FingerprintMismatchError is returned.
+//	state persisted without fingerprint is refused with ErrFingerprintMissing, unless WithoutFingerprintCheck is used.
+func (jd *JobDefinition[T]) Resume(ctx context.Context, jobId string, input T, jobOptions ...JobOptionPreparer) (*JobInstance[T], error) {
+	ji, err := jd.newResumedJobInstance(ctx, jobId, input, jobOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ji.start(ctx); err != nil {
+		return nil, err
+	}
+
+	return ji, nil
+}
+
+// newResumedJobInstance creates a job instance with state restored from StateStore, without starting it.
+func (jd *JobDefinition[T]) newResumedJobInstance(ctx context.Context, jobId string, input T, jobOptions ...JobOptionPreparer) (*JobInstance[T], error) {
+	if !jd.Sealed() {
+		jd.Seal()
+	}
+
+	ji := newJobInstance(jd, input, append(jobOptions, WithJobId(jobId))...)
+	if ji.jobOptions.StateStore == nil {
+		return nil, ErrStateStoreNotConfigured.WithMessage(fmt.Sprintf(MsgStateStoreNotConfigured, jobId))
+	}
+
+	restoredState, err := ji.jobOptions.StateStore.Load(ctx, jobId)
+	if err != nil {
+		return nil, ErrLoadJobState.WithMessage(fmt.Sprintf(MsgLoadJobState, jobId, err))
+	}
+	if persisted, ok := restoredState[fingerprintStateKey]; ok {
+		persistedFingerprint := &DefinitionFingerprint{}
+		if err := json.Unmarshal(persisted, persistedFingerprint); err != nil {
+			return nil, ErrLoadJobState.WithMessage(fmt.Sprintf(MsgLoadJobState, jobId, err))
+		}
+
+		if persistedFingerprint.Hash != jd.Fingerprint().Hash {
+			return nil, &FingerprintMismatchError{
+				JobId:     jobId,
+				Persisted: persistedFingerprint,
+				Current:   jd.Fingerprint(),
+				Diff:      persistedFingerprint.Diff(jd.Fingerprint()),
+			}
+		}
+		delete(restoredState, fingerprintStateKey)
+	} else if len(restoredState) > 0 && !ji.jobOptions.SkipFingerprintCheck {
+		return nil, ErrFingerprintMissing.WithMessage(fmt.Sprintf(MsgFingerprintMissing, jobId))
+	}
+	ji.restoredState = restoredState
+
+	return ji, nil
+}
+
 func (jd *JobDefinition[T]) getRootStep() StepDefinitionMeta {
 	return jd.rootStep
 }
@@ -77,9 +185,20 @@ func (jd *JobDefinition[T]) Seal() {
 	if jd.sealed {
 		return
 	}
+	jd.fingerprint = newDefinitionFingerprint(jd.steps)
 	jd.sealed = true
 }
 
+// Fingerprint describes the steps and their wiring.
+//
+//	it is cached once the definition get sealed, before that it is recomputed on every call, as steps can still be added.
+func (jd *JobDefinition[T]) Fingerprint() *DefinitionFingerprint {
+	if jd.sealed {
+		return jd.fingerprint
+	}
+	return newDefinitionFingerprint(jd.steps)
+}
+
 func (jd *JobDefinition[T]) Sealed() bool {
 	return jd.sealed
 }
@@ -91,16 +210,32 @@ func (jd *JobDefinition[T]) GetStep(stepName string) (StepDefinitionMeta, bool)
 }
 
 // AddStep adds a step to the job definition, with optional preceding steps
+//
+//	same preceding step listed more than once is connected once,
+//	preceding steps are checked before the step is added, so a refused step leaves the graph untouched.
+//	the new step has no outgoing edge, so connecting it can't create a cycle, Connect still checks each new edge.
 func (jd *JobDefinition[T]) addStep(step StepDefinitionMeta, precedingSteps ...StepDefinitionMeta) error {
-	jd.steps[step.GetName()] = step
-	jd.stepsDag.AddNode(step)
+	var uniquePrecedingSteps []StepDefinitionMeta
 	for _, precedingStep := range precedingSteps {
-		if err := jd.stepsDag.Connect(precedingStep, step); err != nil {
-			if errors.Is(err, graph.ErrConnectNotExistingNode) {
-				return ErrRefStepNotInJob.WithMessage(fmt.Sprintf("referenced step %s not found", precedingStep.GetName()))
-			}
+		if precedingStep.GetName() == step.GetName() {
+			return ErrInvalidStepGraph.WithMessage(fmt.Sprintf(MsgInvalidStepGraph, step.GetName(), "step cannot depend on itself"))
+		}
+		if registered, ok := jd.steps[precedingStep.GetName()]; !ok || registered != precedingStep {
+			return ErrRefStepNotInJob.WithMessage(fmt.Sprintf(MsgRefStepNotInJob, precedingStep.GetName()))
+		}
+		if !slices.Contains(uniquePrecedingSteps, precedingStep) {
+			uniquePrecedingSteps = append(uniquePrecedingSteps, precedingStep)
+		}
+	}
+
+	if err := jd.stepsDag.AddNode(step); err != nil {
+		return ErrInvalidStepGraph.WithMessage(fmt.Sprintf(MsgInvalidStepGraph, step.GetName(), err))
+	}
+	jd.steps[step.GetName()] = step
 
-			return err
+	for _, precedingStep := range uniquePrecedingSteps {
+		if err := jd.stepsDag.Connect(precedingStep, step); err != nil {
+			return ErrInvalidStepGraph.WithMessage(fmt.Sprintf(MsgInvalidStepGraph, step.GetName(), err))
 		}
 	}
 
@@ -111,3 +246,54 @@ func (jd *JobDefinition[T]) addStep(step StepDefinitionMeta, precedingSteps ...S
 func (jd *JobDefinition[T]) Visualize() (string, error) {
 	return jd.stepsDag.ToDotGraph()
 }
+
+// VisualizeAs the job definition in given format
+func (jd *JobDefinition[T]) VisualizeAs(format VisualizeFormat) (string, error) {
+	return visualizeAs(jd.stepsDag, format)
+}
+
+// Render the job definition with a custom renderer, like graph.TemplateRenderer
+func (jd *JobDefinition[T]) Render(w io.Writer, renderer graph.Renderer) error {
+	return jd.stepsDag.Render(w, renderer)
+}
+
+// Explain describes the execution plan of the job definition: steps grouped in stages,
+//
+//	steps in same stage don't depend on each other and can run in parallel,
+//	max parallelism is the size of largest stage, each step is listed with the steps it depends on.
+//	the root step holding job input is printed as input, it is not counted as a step or a stage.
+func (jd *JobDefinition[T]) Explain() string {
+	rootName := jd.rootStep.GetName()
+	var levels [][]StepDefinitionMeta
+	for _, level := range jd.stepsDag.Levels() {
+		level = slices.DeleteFunc(level, func(step StepDefinitionMeta) bool { return step.GetName() == rootName })
+		if len(level) > 0 {
+			levels = append(levels, level)
+		}
+	}
+
+	maxParallelism := 0
+	for _, level := range levels {
+		maxParallelism = max(maxParallelism, len(level))
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "job %s: %d steps in %d stages, max parallelism %d\n", jd.name, len(jd.steps)-1, len(levels), maxParallelism)
+	fmt.Fprintf(sb, "input: %s\n", rootName)
+	for i, level := range levels {
+		if len(level) > 1 {
+			fmt.Fprintf(sb, "stage %d (%d steps, in parallel):\n", i+1, len(level))
+		} else {
+			fmt.Fprintf(sb, "stage %d (1 step):\n", i+1)
+		}
+		for _, step := range level {
+			dependsOn := slices.DeleteFunc(slices.Clone(step.DependsOn()), func(name string) bool { return name == rootName })
+			if len(dependsOn) == 0 {
+				fmt.Fprintf(sb, "  - %s\n", step.GetName())
+				continue
+			}
+			fmt.Fprintf(sb, "  - %s <- [%s]\n", step.GetName(), strings.Join(dependsOn, ", "))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,245 @@
+package asyncjob
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JobEventType is the type of a JobEvent.
+type JobEventType string
+
+const (
+	EventStepStarted   JobEventType = "StepStarted"
+	EventStepRetried   JobEventType = "StepRetried"
+	EventStepCompleted JobEventType = "StepCompleted"
+	EventStepFailed    JobEventType = "StepFailed"
+	EventStepBlocked   JobEventType = "StepBlocked"
+	EventJobCompleted  JobEventType = "JobCompleted"
+)
+
+// JobEvent is emitted by a JobInstance when a step or the job itself changes state.
+type JobEvent struct {
+	Type          JobEventType
+	JobInstanceId string
+	// StepName is empty for EventJobCompleted.
+	StepName  string
+	Timestamp time.Time
+	// ExecutionData is a snapshot of the step execution data at the time the event is emitted.
+	ExecutionData StepExecutionData
+	// Error is set for EventStepRetried, EventStepFailed, EventStepBlocked, and EventJobCompleted if the job failed.
+	Error error
+}
+
+// EventOverflowPolicy decides what happens when a subscriber is not keeping up with the events.
+//
+//	events are never blocking step execution, so when buffer is full some event will be dropped.
+type EventOverflowPolicy string
+
+const (
+	// EventOverflowDropNewest drops the incoming event when buffer is full.
+	EventOverflowDropNewest EventOverflowPolicy = "DropNewest"
+	// EventOverflowDropOldest drops the oldest buffered event to make room for the incoming event.
+	EventOverflowDropOldest EventOverflowPolicy = "DropOldest"
+)
+
+const defaultEventBufferSize = 64
+
+type SubscribeOptions struct {
+	BufferSize     int
+	OverflowPolicy EventOverflowPolicy
+}
+
+type SubscribeOptionPreparer func(*SubscribeOptions) *SubscribeOptions
+
+// WithEventBufferSize sets the channel buffer size of the subscription.
+func WithEventBufferSize(size int) SubscribeOptionPreparer {
+	return func(options *SubscribeOptions) *SubscribeOptions {
+		options.BufferSize = size
+		return options
+	}
+}
+
+// WithEventOverflowPolicy sets the policy applied when the subscription buffer is full.
+func WithEventOverflowPolicy(policy EventOverflowPolicy) SubscribeOptionPreparer {
+	return func(options *SubscribeOptions) *SubscribeOptions {
+		options.OverflowPolicy = policy
+		return options
+	}
+}
+
+// EventSubscription receives events from a JobInstance.
+//
+//	channel is closed after EventJobCompleted is delivered, or after Unsubscribe.
+//	a subscription can be attached to one job instance only.
+type EventSubscription struct {
+	options *SubscribeOptions
+	events  chan JobEvent
+	dropped atomic.Uint64
+
+	// mutex guards hub and closed, hub lock is taken before it when both are needed.
+	mutex  sync.Mutex
+	hub    *eventHub
+	closed bool
+}
+
+// NewEventSubscription creates a subscription, which can be attached to a job instance on start with WithEventSubscription.
+//
+//	use JobInstance.Subscribe if you don't need events emitted at the very beginning of the job.
+func NewEventSubscription(optionDecorators ...SubscribeOptionPreparer) *EventSubscription {
+	options := &SubscribeOptions{
+		BufferSize:     defaultEventBufferSize,
+		OverflowPolicy: EventOverflowDropNewest,
+	}
+	for _, decorator := range optionDecorators {
+		options = decorator(options)
+	}
+	if options.BufferSize < 1 {
+		options.BufferSize = 1
+	}
+
+	return &EventSubscription{
+		options: options,
+		events:  make(chan JobEvent, options.BufferSize),
+	}
+}
+
+// Events returns the channel to receive events from.
+func (s *EventSubscription) Events() <-chan JobEvent {
+	return s.events
+}
+
+// Dropped returns number of events dropped because subscriber is not keeping up.
+func (s *EventSubscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe stops receiving events, and closes the events channel.
+func (s *EventSubscription) Unsubscribe() {
+	s.mutex.Lock()
+	hub := s.hub
+	if hub == nil {
+		// not attached to any job instance yet.
+		s.close()
+		s.mutex.Unlock()
+		return
+	}
+	s.mutex.Unlock()
+
+	// hub never changes once attached.
+	hub.unsubscribe(s)
+}
+
+// close closes the events channel once, caller should hold the subscription lock.
+func (s *EventSubscription) close() {
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+}
+
+// deliver never blocks, caller should hold hub lock.
+func (s *EventSubscription) deliver(event JobEvent) {
+	select {
+	case s.events <- event:
+		return
+	default:
+	}
+
+	if s.options.OverflowPolicy == EventOverflowDropOldest {
+		select {
+		case <-s.events:
+			s.dropped.Add(1)
+		default:
+		}
+
+		select {
+		case s.events <- event:
+			return
+		default:
+		}
+	}
+
+	s.dropped.Add(1)
+}
+
+// eventHub fan out events to all subscriptions of a job instance.
+type eventHub struct {
+	mutex          sync.Mutex
+	subscriptions  []*EventSubscription
+	completedEvent *JobEvent
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{}
+}
+
+// attach the subscription to the hub, subscription attached to another hub is refused with ErrSubscriptionAttached.
+func (h *eventHub) attach(sub *EventSubscription, jobInstanceId string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+
+	if sub.hub != nil && sub.hub != h {
+		return ErrSubscriptionAttached.WithMessage(fmt.Sprintf(MsgSubscriptionAttached, jobInstanceId))
+	}
+	if sub.closed || sub.hub == h {
+		return nil
+	}
+	sub.hub = h
+
+	// job already finished, late subscriber still get the final event.
+	if h.completedEvent != nil {
+		sub.deliver(*h.completedEvent)
+		sub.close()
+		return nil
+	}
+
+	h.subscriptions = append(h.subscriptions, sub)
+	return nil
+}
+
+func (h *eventHub) unsubscribe(sub *EventSubscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+
+	if sub.closed {
+		return
+	}
+
+	for i, s := range h.subscriptions {
+		if s == sub {
+			h.subscriptions = append(h.subscriptions[:i], h.subscriptions[i+1:]...)
+			break
+		}
+	}
+	sub.close()
+}
+
+func (h *eventHub) publish(event JobEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.completedEvent != nil {
+		return
+	}
+
+	for _, sub := range h.subscriptions {
+		sub.deliver(event)
+	}
+
+	// EventJobCompleted is the last event of a job instance.
+	if event.Type == EventJobCompleted {
+		h.completedEvent = &event
+		for _, sub := range h.subscriptions {
+			sub.mutex.Lock()
+			sub.close()
+			sub.mutex.Unlock()
+		}
+		h.subscriptions = nil
+	}
+}
@@ -0,0 +1,154 @@
+package asyncjob_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-asyncjob"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobEvents(t *testing.T) {
+	t.Parallel()
+
+	gate := make(chan struct{})
+	job := asyncjob.NewJobDefinition[string]("eventJob")
+	waitStep, err := asyncjob.AddStepWithStaticFunc(job, "WaitForGate", func(ctx context.Context) (string, error) {
+		<-gate
+		return "opened", nil
+	})
+	assert.NoError(t, err)
+
+	attempts := 0
+	_, err = asyncjob.StepAfterWithStaticFunc(job, "FlakyStep", waitStep, func(ctx context.Context, input string) (string, error) {
+		attempts++
+		if attempts < 2 {
+			return "", fmt.Errorf("transient error")
+		}
+		return input, nil
+	}, asyncjob.WithRetry(newLinearRetryPolicy(time.Millisecond, 3)))
+	assert.NoError(t, err)
+
+	subscription := asyncjob.NewEventSubscription()
+	jobInstance := job.Start(context.Background(), "input", asyncjob.WithJobId("eventJob1"), asyncjob.WithEventSubscription(subscription))
+	close(gate)
+
+	var eventTypes []string
+	for event := range subscription.Events() {
+		assert.Equal(t, "eventJob1", event.JobInstanceId)
+		assert.False(t, event.Timestamp.IsZero())
+		eventTypes = append(eventTypes, fmt.Sprintf("%s:%s", event.Type, event.StepName))
+
+		if event.Type == asyncjob.EventStepCompleted && event.StepName == "FlakyStep" {
+			assert.Equal(t, uint(1), event.ExecutionData.Retried.Count)
+		}
+	}
+
+	assert.Equal(t, []string{
+		"StepStarted:WaitForGate",
+		"StepCompleted:WaitForGate",
+		"StepStarted:FlakyStep",
+		"StepRetried:FlakyStep",
+		"StepCompleted:FlakyStep",
+		"JobCompleted:",
+	}, eventTypes)
+	assert.Equal(t, uint64(0), subscription.Dropped())
+
+	// late subscriber still receive the final event.
+	lateSubscription := jobInstance.Subscribe()
+	lastEvent, ok := <-lateSubscription.Events()
+	assert.True(t, ok)
+	assert.Equal(t, asyncjob.EventJobCompleted, lastEvent.Type)
+	_, ok = <-lateSubscription.Events()
+	assert.False(t, ok)
+}
+
+func TestJobEventsSlowSubscriber(t *testing.T) {
+	t.Parallel()
+
+	gate := make(chan struct{})
+	job := asyncjob.NewJobDefinition[string]("slowSubscriberJob")
+	waitStep, err := asyncjob.AddStepWithStaticFunc(job, "WaitForGate", func(ctx context.Context) (string, error) {
+		<-gate
+		return "opened", nil
+	})
+	assert.NoError(t, err)
+	_, err = asyncjob.StepAfterWithStaticFunc(job, "Echo", waitStep, func(ctx context.Context, input string) (string, error) {
+		return input, nil
+	})
+	assert.NoError(t, err)
+
+	dropNewest := asyncjob.NewEventSubscription(asyncjob.WithEventBufferSize(1))
+	dropOldest := asyncjob.NewEventSubscription(asyncjob.WithEventBufferSize(1), asyncjob.WithEventOverflowPolicy(asyncjob.EventOverflowDropOldest))
+	jobInstance := job.Start(context.Background(), "input", asyncjob.WithEventSubscription(dropNewest), asyncjob.WithEventSubscription(dropOldest))
+	unsubscribed := jobInstance.Subscribe()
+	unsubscribed.Unsubscribe()
+	close(gate)
+
+	// nobody is reading, job should still finish.
+	assert.NoError(t, jobInstance.Wait(context.Background()))
+
+	// drain a fresh subscription, it get closed after EventJobCompleted is published.
+	for range jobInstance.Subscribe().Events() {
+	}
+
+	var received []asyncjob.JobEvent
+	for event := range dropNewest.Events() {
+		received = append(received, event)
+	}
+	assert.Len(t, received, 1)
+	assert.Equal(t, asyncjob.EventStepStarted, received[0].Type)
+	assert.Equal(t, uint64(4), dropNewest.Dropped())
+
+	received = nil
+	for event := range dropOldest.Events() {
+		received = append(received, event)
+	}
+	assert.Len(t, received, 1)
+	assert.Equal(t, asyncjob.EventJobCompleted, received[0].Type)
+	assert.Equal(t, uint64(4), dropOldest.Dropped())
+
+	_, ok := <-unsubscribed.Events()
+	assert.False(t, ok)
+}
+
+func TestJobEventsSubscriptionAttachedOnce(t *testing.T) {
+	t.Parallel()
+
+	job := asyncjob.NewJobDefinition[string]("attachOnceJob")
+	_, err := asyncjob.AddStepWithStaticFunc(job, "Echo", func(ctx context.Context) (string, error) {
+		return "echo", nil
+	})
+	assert.NoError(t, err)
+
+	subscription := asyncjob.NewEventSubscription()
+	first := job.Start(context.Background(), "input", asyncjob.WithEventSubscription(subscription))
+
+	// same subscription to another job instance is refused, it keeps receiving from the first one.
+	second, err := job.StartE(context.Background(), "input", asyncjob.WithEventSubscription(subscription))
+	assert.Nil(t, second)
+	assert.ErrorIs(t, err, asyncjob.ErrSubscriptionAttached)
+
+	third := job.Start(context.Background(), "input", asyncjob.WithEventSubscription(subscription))
+	assert.ErrorIs(t, third.Wait(context.Background()), asyncjob.ErrSubscriptionAttached)
+	assert.Equal(t, asyncjob.JobStateFailed, third.GetState())
+
+	var last asyncjob.JobEvent
+	for event := range subscription.Events() {
+		assert.Equal(t, first.GetJobInstanceId(), event.JobInstanceId)
+		last = event
+	}
+	assert.Equal(t, asyncjob.EventJobCompleted, last.Type)
+
+	// unsubscribe while attaching, both can be called from any routine.
+	for i := 0; i < 10; i++ {
+		racing := asyncjob.NewEventSubscription()
+		go racing.Unsubscribe()
+		jobInstance := job.Start(context.Background(), "input", asyncjob.WithEventSubscription(racing))
+		for range racing.Events() {
+		}
+		assert.NoError(t, jobInstance.Wait(context.Background()))
+	}
+}
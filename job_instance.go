@@ -2,7 +2,11 @@ package asyncjob
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/Azure/go-asyncjob/graph"
 	"github.com/Azure/go-asynctask"
@@ -13,16 +17,76 @@ type JobInstanceMeta interface {
 	GetJobInstanceId() string
 	GetJobDefinition() JobDefinitionMeta
 	GetStepInstance(stepName string) (StepInstanceMeta, bool)
+	GetState() JobState
+	StartTime() time.Time
+	EndTime() time.Time
+	Done() <-chan struct{}
+	Err() error
+	Cancel()
 	Wait(context.Context) error
 	Visualize() (string, error)
+	VisualizeAs(format VisualizeFormat) (string, error)
+	Render(w io.Writer, renderer graph.Renderer) error
+	Subscribe(...SubscribeOptionPreparer) *EventSubscription
+	Snapshot() *JobInstanceSnapshot
 
 	// not exposing for now
 	addStepInstance(step StepInstanceMeta, precedingSteps ...StepInstanceMeta)
+	emitEvent(event JobEvent)
+	saveStepState(ctx context.Context, stepName string, result any) error
+	loadStepState(stepName string, result any) (bool, error)
+	getJobOptions() *JobExecutionOptions
+	start(ctx context.Context) error
+	abort(err error)
+	attachSubscriptions(subscriptions []*EventSubscription) error
+}
+
+type JobState string
+
+const JobStatePending JobState = "pending"
+const JobStateRunning JobState = "running"
+const JobStateFailed JobState = "failed"
+const JobStateCompleted JobState = "completed"
+const JobStateCanceled JobState = "canceled"
+
+// IsTerminal tells whether the job finished.
+func (s JobState) IsTerminal() bool {
+	return s == JobStateFailed || s == JobStateCompleted || s == JobStateCanceled
 }
 
 type JobExecutionOptions struct {
-	Id              string
-	RunSequentially bool
+	Id                 string
+	RunSequentially    bool
+	EventSubscriptions []*EventSubscription
+	StateStore         StateStore
+	StepCodec          Codec
+	// SkipFingerprintCheck resumes state persisted without definition fingerprint, instead of refusing it.
+	SkipFingerprintCheck bool
+
+	// ConcurrencyKey and ConcurrencyPolicy are honored by JobRunner only.
+	ConcurrencyKey    string
+	ConcurrencyPolicy ConcurrencyPolicy
+}
+
+// ConcurrencyPolicy decides what JobRunner does when starting a job instance, while another job instance with same concurrency key is not finished.
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyPolicyReject fails the start with ErrConcurrencyKeyConflict.
+	ConcurrencyPolicyReject ConcurrencyPolicy = "Reject"
+	// ConcurrencyPolicyQueue keeps the new job instance pending, until previous job instances with same key finished.
+	ConcurrencyPolicyQueue ConcurrencyPolicy = "Queue"
+	// ConcurrencyPolicyCancelPrevious cancels previous job instances with same key, new job instance starts after they finished.
+	ConcurrencyPolicyCancelPrevious ConcurrencyPolicy = "CancelPrevious"
+)
+
+func (p ConcurrencyPolicy) valid() bool {
+	switch p {
+	case ConcurrencyPolicyReject, ConcurrencyPolicyQueue, ConcurrencyPolicyCancelPrevious:
+		return true
+	default:
+		return false
+	}
 }
 
 type JobOptionPreparer func(*JobExecutionOptions) *JobExecutionOptions
@@ -41,6 +105,50 @@ func WithSequentialExecution() JobOptionPreparer {
 	}
 }
 
+// WithConcurrencyKey makes sure only one job instance with the key runs at a time, when started through JobRunner.
+//
+//	JobRunner refuses to start the job instance with ErrUnknownConcurrencyPolicy, if policy is not one of ConcurrencyPolicyXxx.
+func WithConcurrencyKey(key string, policy ConcurrencyPolicy) JobOptionPreparer {
+	return func(options *JobExecutionOptions) *JobExecutionOptions {
+		options.ConcurrencyKey = key
+		options.ConcurrencyPolicy = policy
+		return options
+	}
+}
+
+// WithStateStore persists output of each completed step into the store, so job instance can be resumed with JobDefinition.Resume.
+func WithStateStore(store StateStore) JobOptionPreparer {
+	return func(options *JobExecutionOptions) *JobExecutionOptions {
+		options.StateStore = store
+		return options
+	}
+}
+
+// WithStepCodec sets the codec to encode step output for StateStore, JSONCodec is used by default.
+func WithStepCodec(codec Codec) JobOptionPreparer {
+	return func(options *JobExecutionOptions) *JobExecutionOptions {
+		options.StepCodec = codec
+		return options
+	}
+}
+
+// WithoutFingerprintCheck allows Resume to restore state persisted without definition fingerprint,
+// the caller is responsible to make sure the definition didn't change since the state was persisted.
+func WithoutFingerprintCheck() JobOptionPreparer {
+	return func(options *JobExecutionOptions) *JobExecutionOptions {
+		options.SkipFingerprintCheck = true
+		return options
+	}
+}
+
+// WithEventSubscription attach the subscription to job instance before any step starts.
+func WithEventSubscription(subscription *EventSubscription) JobOptionPreparer {
+	return func(options *JobExecutionOptions) *JobExecutionOptions {
+		options.EventSubscriptions = append(options.EventSubscriptions, subscription)
+		return options
+	}
+}
+
 // JobInstance is the instance of a jobDefinition
 type JobInstance[T any] struct {
 	jobOptions *JobExecutionOptions
@@ -49,6 +157,25 @@ type JobInstance[T any] struct {
 	rootStep   *StepInstance[T]
 	steps      map[string]StepInstanceMeta
 	stepsDag   *graph.Graph[StepInstanceMeta]
+	events     *eventHub
+	state      JobState
+	startTime  time.Time
+	endTime    time.Time
+	err        error
+	started    chan struct{}
+	done       chan struct{}
+	cancelFunc context.CancelFunc
+	// aborted is set when job instance finished before start, like canceled while pending.
+	aborted bool
+	mutex   sync.RWMutex
+	// initErr is found when creating the job instance, like a subscription attached elsewhere, job instance fails on start with it.
+	initErr error
+
+	// step output loaded from StateStore on resume, keyed by step name.
+	restoredState map[string][]byte
+	// definition fingerprint is persisted along with the first step output.
+	saveFingerprintOnce sync.Once
+	saveFingerprintErr  error
 }
 
 func newJobInstance[T any](jd *JobDefinition[T], input T, jobInstanceOptions ...JobOptionPreparer) *JobInstance[T] {
@@ -56,28 +183,77 @@ func newJobInstance[T any](jd *JobDefinition[T], input T, jobInstanceOptions ...
 		Definition: jd,
 		input:      input,
 		steps:      map[string]StepInstanceMeta{},
-		stepsDag:   graph.NewGraph(connectStepInstance),
-		jobOptions: &JobExecutionOptions{},
+		stepsDag:   graph.NewGraph(connectStepInstance, jd.options.StepOrder...),
+		jobOptions: newJobExecutionOptions(jobInstanceOptions...),
+		events:     newEventHub(),
+		state:      JobStatePending,
+		started:    make(chan struct{}),
+		done:       make(chan struct{}),
 	}
 
-	for _, decorator := range jobInstanceOptions {
-		ji.jobOptions = decorator(ji.jobOptions)
+	ji.initErr = ji.attachSubscriptions(ji.jobOptions.EventSubscriptions)
+
+	return ji
+}
+
+// newJobExecutionOptions applies the option preparers, with default job id and codec filled.
+func newJobExecutionOptions(jobOptions ...JobOptionPreparer) *JobExecutionOptions {
+	options := &JobExecutionOptions{}
+	for _, decorator := range jobOptions {
+		options = decorator(options)
 	}
 
-	if ji.jobOptions.Id == "" {
-		ji.jobOptions.Id = uuid.New().String()
+	if options.Id == "" {
+		options.Id = uuid.New().String()
 	}
 
-	return ji
+	if options.StepCodec == nil {
+		options.StepCodec = JSONCodec{}
+	}
+
+	return options
+}
+
+// attachSubscriptions attach all subscriptions to the job instance, returns the first subscription refused.
+func (ji *JobInstance[T]) attachSubscriptions(subscriptions []*EventSubscription) error {
+	var firstErr error
+	for _, subscription := range subscriptions {
+		if err := ji.events.attach(subscription, ji.GetJobInstanceId()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
 
-func (ji *JobInstance[T]) start(ctx context.Context) {
+// start constructs step instances and starts execution.
+//
+//	if a step instance failed to construct, steps already started are canceled,
+//	and the job instance finishes with the construction error, which is also returned.
+func (ji *JobInstance[T]) start(ctx context.Context) error {
+	if ji.initErr != nil {
+		ji.abort(ji.initErr)
+		return ji.initErr
+	}
+
+	ctx, cancelFunc := context.WithCancel(ctx)
+
+	ji.mutex.Lock()
+	if ji.aborted || ji.state != JobStatePending {
+		// aborted before start
+		ji.mutex.Unlock()
+		cancelFunc()
+		return nil
+	}
+	ji.state = JobStateRunning
+	ji.startTime = time.Now()
+	ji.cancelFunc = cancelFunc
+	ji.mutex.Unlock()
+
 	// create root step instance
 	ji.rootStep = newStepInstance(ji.Definition.rootStep, ji)
 	ji.rootStep.task = asynctask.NewCompletedTask(ji.input)
 	ji.rootStep.state = StepStateCompleted
-	ji.steps[ji.rootStep.GetName()] = ji.rootStep
-	ji.stepsDag.AddNode(ji.rootStep)
+	ji.addStepInstance(ji.rootStep)
 
 	// construct job instance graph, with TopologySort ordering
 	orderedSteps := ji.Definition.stepsDag.TopologicalSort()
@@ -85,12 +261,150 @@ func (ji *JobInstance[T]) start(ctx context.Context) {
 		if stepDef.GetName() == ji.Definition.GetName() {
 			continue
 		}
-		ji.steps[stepDef.GetName()] = stepDef.createStepInstance(ctx, ji)
+		stepInstance, err := stepDef.createStepInstance(ctx, ji)
+		if err != nil {
+			cancelFunc()
+			close(ji.started)
+			go ji.notifyCompletion(err)
+			return err
+		}
 
 		if ji.jobOptions.RunSequentially {
-			ji.steps[stepDef.GetName()].Waitable().Wait(ctx)
+			stepInstance.Waitable().Wait(ctx)
 		}
 	}
+	close(ji.started)
+
+	go ji.notifyCompletion(nil)
+	return nil
+}
+
+// notifyCompletion waits for all steps to finish, then finish the job instance.
+//
+//	constructErr overrides errors of the steps, as they are canceled because of it.
+func (ji *JobInstance[T]) notifyCompletion(constructErr error) {
+	var tasks []asynctask.Waitable
+	for _, step := range ji.getSteps() {
+		tasks = append(tasks, step.Waitable())
+	}
+
+	err := asynctask.WaitAll(context.Background(), &asynctask.WaitAllOptions{}, tasks...)
+	if constructErr != nil {
+		ji.finish(constructErr)
+		return
+	}
+
+	// record rootCaused error if possible
+	jobErr := &JobError{}
+	if errors.As(err, &jobErr) {
+		err = jobErr.RootCause()
+	}
+
+	ji.finish(err)
+}
+
+// abort finishes a job instance not started yet, it is no-op if job instance already started.
+func (ji *JobInstance[T]) abort(err error) {
+	ji.mutex.Lock()
+	aborting := ji.markAborted()
+	ji.mutex.Unlock()
+
+	if aborting {
+		ji.finish(err)
+	}
+}
+
+// markAborted flags a pending job instance as aborted, so it never starts. returns false if it is not pending.
+//
+//	caller should hold the write lock, and finish the job instance if true is returned.
+func (ji *JobInstance[T]) markAborted() bool {
+	if ji.aborted || ji.state != JobStatePending {
+		return false
+	}
+	ji.aborted = true
+	ji.startTime = time.Now()
+	ji.state = JobStateRunning
+	return true
+}
+
+// finish update job state by the error, then emits EventJobCompleted.
+func (ji *JobInstance[T]) finish(err error) {
+	ji.mutex.Lock()
+	ji.endTime = time.Now()
+	ji.err = err
+	switch {
+	case err == nil:
+		ji.state = JobStateCompleted
+	case errors.Is(err, context.Canceled) || errors.Is(err, asynctask.ErrCanceled):
+		ji.state = JobStateCanceled
+	default:
+		ji.state = JobStateFailed
+	}
+	close(ji.done)
+	if ji.cancelFunc != nil {
+		ji.cancelFunc()
+	}
+	ji.mutex.Unlock()
+
+	ji.emitEvent(JobEvent{
+		Type:          EventJobCompleted,
+		JobInstanceId: ji.GetJobInstanceId(),
+		Timestamp:     ji.endTime,
+		Error:         err,
+	})
+}
+
+// Cancel the context passed to all steps, steps should honor the context to stop early.
+//
+//	job instance is Canceled once all steps finished.
+//	job instance not started yet (queued by JobRunner) is Canceled immediately.
+func (ji *JobInstance[T]) Cancel() {
+	// check and abort under same lock, so a pending job instance can't start in between.
+	ji.mutex.Lock()
+	cancelFunc := ji.cancelFunc
+	aborting := cancelFunc == nil && ji.markAborted()
+	ji.mutex.Unlock()
+
+	if cancelFunc != nil {
+		cancelFunc()
+		return
+	}
+	if aborting {
+		ji.finish(context.Canceled)
+	}
+}
+
+// GetState returns the aggregated state of the job instance.
+func (ji *JobInstance[T]) GetState() JobState {
+	ji.mutex.RLock()
+	defer ji.mutex.RUnlock()
+	return ji.state
+}
+
+// StartTime returns the time job instance started, zero if not started yet.
+func (ji *JobInstance[T]) StartTime() time.Time {
+	ji.mutex.RLock()
+	defer ji.mutex.RUnlock()
+	return ji.startTime
+}
+
+// EndTime returns the time all steps in job instance finished, zero if not finished yet.
+func (ji *JobInstance[T]) EndTime() time.Time {
+	ji.mutex.RLock()
+	defer ji.mutex.RUnlock()
+	return ji.endTime
+}
+
+// Done returns a channel that's closed when all steps in job instance finished.
+func (ji *JobInstance[T]) Done() <-chan struct{} {
+	return ji.done
+}
+
+// Err returns the root caused error of the job instance after Done is closed, nil otherwise.
+func (ji *JobInstance[T]) Err() error {
+	ji.mutex.RLock()
+	defer ji.mutex.RUnlock()
+	return ji.err
 }
 
 func (ji *JobInstance[T]) GetJobInstanceId() string {
@@ -103,11 +417,28 @@ func (ji *JobInstance[T]) GetJobDefinition() JobDefinitionMeta {
 
 // GetStepInstance returns the stepInstance by name
 func (ji *JobInstance[T]) GetStepInstance(stepName string) (StepInstanceMeta, bool) {
+	ji.mutex.RLock()
+	defer ji.mutex.RUnlock()
 	stepMeta, ok := ji.steps[stepName]
 	return stepMeta, ok
 }
 
+// getSteps returns a copy of step instances, safe to iterate while job is starting.
+func (ji *JobInstance[T]) getSteps() []StepInstanceMeta {
+	ji.mutex.RLock()
+	defer ji.mutex.RUnlock()
+
+	steps := make([]StepInstanceMeta, 0, len(ji.steps))
+	for _, step := range ji.steps {
+		steps = append(steps, step)
+	}
+	return steps
+}
+
 func (ji *JobInstance[T]) addStepInstance(step StepInstanceMeta, precedingSteps ...StepInstanceMeta) {
+	ji.mutex.Lock()
+	defer ji.mutex.Unlock()
+
 	ji.steps[step.GetName()] = step
 
 	ji.stepsDag.AddNode(step)
@@ -117,28 +448,161 @@ func (ji *JobInstance[T]) addStepInstance(step StepInstanceMeta, precedingSteps
 }
 
 // Wait for all steps in the job to finish.
+//
+//	returns the root caused error if job failed.
+//	panics with PanicError if a step with PanicPolicyRepanic panicked.
 func (ji *JobInstance[T]) Wait(ctx context.Context) error {
-	var tasks []asynctask.Waitable
-	for _, step := range ji.steps {
-		tasks = append(tasks, step.Waitable())
+	select {
+	case <-ji.done:
+		repanic(ji.Err())
+		return ji.Err()
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	err := asynctask.WaitAll(ctx, &asynctask.WaitAllOptions{}, tasks...)
+// WaitAll waits for all steps in the job to finish, like Wait, but reports every independent step failure.
+//
+//	returns *MultiJobError if any step failed, with steps blocked by each failure.
+//	returns the job error as is if the job failed without any step failure, like canceled before start.
+func (ji *JobInstance[T]) WaitAll(ctx context.Context) error {
+	select {
+	case <-ji.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if ji.Err() == nil {
+		return nil
+	}
+	repanic(ji.Err())
 
-	// return rootCaused error if possible
-	if err != nil {
+	ji.mutex.RLock()
+	defer ji.mutex.RUnlock()
+
+	multiErr := &MultiJobError{}
+	for _, step := range ji.stepsDag.TopologicalSort() {
 		jobErr := &JobError{}
-		if errors.As(err, &jobErr) {
-			return jobErr.RootCause()
+		if !errors.As(step.GetError(), &jobErr) || jobErr.Code == ErrPrecedentStepFailed {
+			continue
+		}
+
+		failure := &StepFailure{StepName: step.GetName(), Code: jobErr.Code, Err: jobErr, BlockedSteps: []string{}}
+		descendants, _ := ji.stepsDag.Descendants(step)
+		blocked := make(map[string]bool, len(descendants))
+		for _, descendant := range descendants {
+			if descendant.GetState() != StepStateCompleted {
+				blocked[descendant.GetName()] = true
+			}
+		}
+		for _, downstream := range ji.stepsDag.TopologicalSort() {
+			if blocked[downstream.GetName()] {
+				failure.BlockedSteps = append(failure.BlockedSteps, downstream.GetName())
+			}
+		}
+		multiErr.Failures = append(multiErr.Failures, failure)
+	}
+
+	if len(multiErr.Failures) == 0 {
+		return ji.Err()
+	}
+	return multiErr
+}
+
+// repanic panics with the PanicError, if err is from a step panicked with PanicPolicyRepanic.
+func repanic(err error) {
+	jobErr := &JobError{}
+	if !errors.As(err, &jobErr) || jobErr.Code != ErrStepPanicked || jobErr.StepInstance == nil {
+		return
+	}
+	if jobErr.StepInstance.GetStepDefinition().getExecutionOptions().PanicPolicy != PanicPolicyRepanic {
+		return
+	}
+
+	panicErr := &PanicError{}
+	if errors.As(jobErr, &panicErr) {
+		panic(panicErr)
+	}
+}
+
+func (ji *JobInstance[T]) getJobOptions() *JobExecutionOptions {
+	return ji.jobOptions
+}
+
+// jobInstance gives access to strong typed JobInstance, from JobInstance and JobInstanceWithResult.
+func (ji *JobInstance[T]) jobInstance() *JobInstance[T] {
+	return ji
+}
+
+// saveStepState persists step output if StateStore is configured.
+func (ji *JobInstance[T]) saveStepState(ctx context.Context, stepName string, result any) error {
+	if ji.jobOptions.StateStore == nil {
+		return nil
+	}
+
+	ji.saveFingerprintOnce.Do(func() {
+		fingerprintJson, err := json.Marshal(ji.Definition.Fingerprint())
+		if err != nil {
+			ji.saveFingerprintErr = err
+			return
 		}
+		ji.saveFingerprintErr = ji.jobOptions.StateStore.Save(ctx, ji.GetJobInstanceId(), fingerprintStateKey, fingerprintJson)
+	})
+	if ji.saveFingerprintErr != nil {
+		return ji.saveFingerprintErr
+	}
 
+	data, err := ji.jobOptions.StepCodec.Marshal(result)
+	if err != nil {
 		return err
 	}
+	return ji.jobOptions.StateStore.Save(ctx, ji.GetJobInstanceId(), stepName, data)
+}
 
-	return nil
+// loadStepState decodes step output restored from StateStore into result, returns false if step have no state to restore.
+func (ji *JobInstance[T]) loadStepState(stepName string, result any) (bool, error) {
+	data, ok := ji.restoredState[stepName]
+	if !ok {
+		return false, nil
+	}
+
+	if err := ji.jobOptions.StepCodec.Unmarshal(data, result); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Subscribe to events of this job instance.
+//
+//	events emitted before subscribing are not replayed, except EventJobCompleted.
+//	slow subscriber never blocks step execution, see EventOverflowPolicy.
+func (ji *JobInstance[T]) Subscribe(optionDecorators ...SubscribeOptionPreparer) *EventSubscription {
+	subscription := NewEventSubscription(optionDecorators...)
+	// new subscription is not attached anywhere else, never refused.
+	_ = ji.events.attach(subscription, ji.GetJobInstanceId())
+	return subscription
+}
+
+func (ji *JobInstance[T]) emitEvent(event JobEvent) {
+	ji.events.publish(event)
 }
 
 // Visualize the job instance in graphviz dot format
 func (jd *JobInstance[T]) Visualize() (string, error) {
+	jd.mutex.RLock()
+	defer jd.mutex.RUnlock()
 	return jd.stepsDag.ToDotGraph()
 }
+
+// VisualizeAs the job instance in given format
+func (ji *JobInstance[T]) VisualizeAs(format VisualizeFormat) (string, error) {
+	ji.mutex.RLock()
+	defer ji.mutex.RUnlock()
+	return visualizeAs(ji.stepsDag, format)
+}
+
+// Render the job instance with a custom renderer, like graph.TemplateRenderer
+func (ji *JobInstance[T]) Render(w io.Writer, renderer graph.Renderer) error {
+	ji.mutex.RLock()
+	defer ji.mutex.RUnlock()
+	return ji.stepsDag.Render(w, renderer)
+}
@@ -23,21 +23,69 @@ func JobWithResult[Tin, Tout any](jd *JobDefinition[Tin], resultStep *StepDefini
 
 type JobInstanceWithResult[Tin, Tout any] struct {
 	*JobInstance[Tin]
-	resultStep *StepInstance[Tout]
+	resultStep *StepDefinition[Tout]
+}
+
+func newJobInstanceWithResult[Tin, Tout any](ji *JobInstance[Tin], resultStep *StepDefinition[Tout]) *JobInstanceWithResult[Tin, Tout] {
+	return &JobInstanceWithResult[Tin, Tout]{
+		JobInstance: ji,
+		resultStep:  resultStep,
+	}
 }
 
 func (jd *JobDefinitionWithResult[Tin, Tout]) Start(ctx context.Context, input Tin, jobOptions ...JobOptionPreparer) *JobInstanceWithResult[Tin, Tout] {
 	ji := jd.JobDefinition.Start(ctx, input, jobOptions...)
 
-	return &JobInstanceWithResult[Tin, Tout]{
-		JobInstance: ji,
-		resultStep:  getStrongTypedStepInstance(jd.resultStep, ji),
+	return newJobInstanceWithResult(ji, jd.resultStep)
+}
+
+// StartE is same as Start, but returns error if the job instance failed to construct, see JobDefinition.StartE
+func (jd *JobDefinitionWithResult[Tin, Tout]) StartE(ctx context.Context, input Tin, jobOptions ...JobOptionPreparer) (*JobInstanceWithResult[Tin, Tout], error) {
+	ji, err := jd.JobDefinition.StartE(ctx, input, jobOptions...)
+	if err != nil {
+		return nil, err
 	}
+
+	return newJobInstanceWithResult(ji, jd.resultStep), nil
+}
+
+// Resume a job instance persisted with WithStateStore, see JobDefinition.Resume
+func (jd *JobDefinitionWithResult[Tin, Tout]) Resume(ctx context.Context, jobId string, input Tin, jobOptions ...JobOptionPreparer) (*JobInstanceWithResult[Tin, Tout], error) {
+	ji, err := jd.JobDefinition.Resume(ctx, jobId, input, jobOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newJobInstanceWithResult(ji, jd.resultStep), nil
 }
 
 // Result returns the result of the job from result step.
 //
 //	it doesn't wait for all steps to finish, you can use Result() after Wait() if desired.
+//	job instance queued by JobRunner is waited to start first.
+//	result step panicked with PanicPolicyRepanic panics again here, like Wait.
 func (ji *JobInstanceWithResult[Tin, Tout]) Result(ctx context.Context) (Tout, error) {
-	return ji.resultStep.task.Result(ctx)
+	var result Tout
+	select {
+	case <-ji.started:
+	case <-ji.done:
+	case <-ctx.Done():
+		return result, ctx.Err()
+	}
+
+	select {
+	case <-ji.started:
+	default:
+		// aborted before start
+		return result, ji.Err()
+	}
+
+	resultStepInstance, err := getStrongTypedStepInstance(ji.resultStep, ji.JobInstance)
+	if err != nil {
+		return result, err
+	}
+
+	result, err = resultStepInstance.task.Result(ctx)
+	repanic(err)
+	return result, err
 }
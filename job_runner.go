@@ -0,0 +1,418 @@
+package asyncjob
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type JobRunnerOptions struct {
+	// FinishedInstanceTTL is how long a finished job instance is kept in the runner, 0 means forever.
+	FinishedInstanceTTL time.Duration
+}
+
+type JobRunnerOptionPreparer func(*JobRunnerOptions) *JobRunnerOptions
+
+// WithFinishedInstanceTTL evicts job instances from the runner, after they are finished for given duration.
+func WithFinishedInstanceTTL(ttl time.Duration) JobRunnerOptionPreparer {
+	return func(options *JobRunnerOptions) *JobRunnerOptions {
+		options.FinishedInstanceTTL = ttl
+		return options
+	}
+}
+
+// JobInstanceFilter selects job instances in JobRunner.List, zero value fields are not filtering.
+type JobInstanceFilter struct {
+	DefinitionName string
+	States         []JobState
+	StartedAfter   time.Time
+	StartedBefore  time.Time
+}
+
+func (f *JobInstanceFilter) match(ji JobInstanceMeta) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.DefinitionName != "" && f.DefinitionName != ji.GetJobDefinition().GetName() {
+		return false
+	}
+
+	if len(f.States) > 0 {
+		state := ji.GetState()
+		matchState := false
+		for _, s := range f.States {
+			if s == state {
+				matchState = true
+				break
+			}
+		}
+		if !matchState {
+			return false
+		}
+	}
+
+	startTime := ji.StartTime()
+	if !f.StartedAfter.IsZero() && startTime.Before(f.StartedAfter) {
+		return false
+	}
+	if !f.StartedBefore.IsZero() && !startTime.Before(f.StartedBefore) {
+		return false
+	}
+
+	return true
+}
+
+// JobRunner starts job instances from registered job definitions, and keeps track of running and recently finished job instances by id.
+type JobRunner struct {
+	options     *JobRunnerOptions
+	mutex       sync.RWMutex
+	definitions map[string]JobDefinitionMeta
+	instances   map[string]JobInstanceMeta
+	// concurrencyKeys tracks job instances not finished yet by concurrency key, in start order.
+	concurrencyKeys map[string][]JobInstanceMeta
+	// retries starts a tracked job instance again, by job id.
+	retries map[string]jobRetryFunc
+}
+
+// jobRetryFunc starts the job definition again with same input and options, to replace the previous job instance.
+type jobRetryFunc func(ctx context.Context, previous JobInstanceMeta) (JobInstanceMeta, error)
+
+func NewJobRunner(optionDecorators ...JobRunnerOptionPreparer) *JobRunner {
+	r := &JobRunner{
+		options:         &JobRunnerOptions{},
+		definitions:     make(map[string]JobDefinitionMeta),
+		instances:       make(map[string]JobInstanceMeta),
+		concurrencyKeys: make(map[string][]JobInstanceMeta),
+		retries:         make(map[string]jobRetryFunc),
+	}
+
+	for _, decorator := range optionDecorators {
+		r.options = decorator(r.options)
+	}
+
+	return r
+}
+
+// Register a job definition to the runner, definition name should be unique in the runner.
+func (r *JobRunner) Register(jd JobDefinitionMeta) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if registered, ok := r.definitions[jd.GetName()]; ok && registered.getRootStep() != jd.getRootStep() {
+		return ErrDuplicateJobDefinition.WithMessage(fmt.Sprintf(MsgDuplicateJobDefinition, jd.GetName()))
+	}
+	r.definitions[jd.GetName()] = jd
+	return nil
+}
+
+// GetDefinition returns registered job definition by name
+func (r *JobRunner) GetDefinition(name string) (JobDefinitionMeta, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	jd, ok := r.definitions[name]
+	return jd, ok
+}
+
+// StartJob starts a job instance from a job definition registered in the runner, and keeps track of it.
+//
+//	start is idempotent by job id: if a job instance with same id (see WithJobId) is tracked, it is returned instead,
+//	with event subscriptions from jobOptions attached to it.
+//	job instances with same concurrency key (see WithConcurrencyKey) are handled by the ConcurrencyPolicy,
+//	a queued job instance stays Pending until it starts.
+func StartJob[T any](ctx context.Context, r *JobRunner, jd *JobDefinition[T], input T, jobOptions ...JobOptionPreparer) (*JobInstance[T], error) {
+	if err := r.checkRegistered(jd); err != nil {
+		return nil, err
+	}
+
+	if !jd.Sealed() {
+		jd.Seal()
+	}
+
+	wrap := func(ji *JobInstance[T]) JobInstanceMeta { return ji }
+	options, jobOptions := resolveJobId(jobOptions)
+	newInstance := func() JobInstanceMeta { return newJobInstance(jd, input, jobOptions...) }
+	ji, err := r.run(ctx, jd, options, newInstance, nil, newJobRetryFunc(r, jd, input, jobOptions, wrap))
+	if err != nil {
+		return nil, err
+	}
+
+	return ji.(interface{ jobInstance() *JobInstance[T] }).jobInstance(), nil
+}
+
+// StartJobWithResult is same as StartJob, for job definition with result.
+func StartJobWithResult[Tin, Tout any](ctx context.Context, r *JobRunner, jd *JobDefinitionWithResult[Tin, Tout], input Tin, jobOptions ...JobOptionPreparer) (*JobInstanceWithResult[Tin, Tout], error) {
+	if err := r.checkRegistered(jd); err != nil {
+		return nil, err
+	}
+
+	if !jd.Sealed() {
+		jd.Seal()
+	}
+
+	wrap := func(ji *JobInstance[Tin]) JobInstanceMeta { return newJobInstanceWithResult(ji, jd.resultStep) }
+	options, jobOptions := resolveJobId(jobOptions)
+	newInstance := func() JobInstanceMeta { return wrap(newJobInstance(jd.JobDefinition, input, jobOptions...)) }
+	ji, err := r.run(ctx, jd, options, newInstance, nil, newJobRetryFunc(r, jd.JobDefinition, input, jobOptions, wrap))
+	if err != nil {
+		return nil, err
+	}
+
+	if withResult, ok := ji.(*JobInstanceWithResult[Tin, Tout]); ok && withResult.resultStep == jd.resultStep {
+		return withResult, nil
+	}
+	return newJobInstanceWithResult(ji.(interface{ jobInstance() *JobInstance[Tin] }).jobInstance(), jd.resultStep), nil
+}
+
+// Retry starts a finished job instance again, with same input and options.
+//
+//	if the job instance has a StateStore configured, it is resumed with same job id (see JobDefinition.Resume), replacing the finished one;
+//	otherwise a new job instance is started with a new job id.
+//	event subscriptions of the finished job instance are not carried over.
+func (r *JobRunner) Retry(ctx context.Context, jobId string) (JobInstanceMeta, error) {
+	r.mutex.RLock()
+	ji, ok := r.instances[jobId]
+	retry := r.retries[jobId]
+	r.mutex.RUnlock()
+
+	if !ok {
+		return nil, ErrJobInstanceNotFound.WithMessage(fmt.Sprintf(MsgJobInstanceNotFound, jobId))
+	}
+	if state := ji.GetState(); !state.IsTerminal() {
+		return nil, ErrJobInstanceNotFinished.WithMessage(fmt.Sprintf(MsgJobInstanceNotFinished, jobId, state))
+	}
+
+	return retry(ctx, ji)
+}
+
+func newJobRetryFunc[T any](r *JobRunner, jd *JobDefinition[T], input T, jobOptions []JobOptionPreparer, wrap func(*JobInstance[T]) JobInstanceMeta) jobRetryFunc {
+	var retry jobRetryFunc
+	retry = func(ctx context.Context, previous JobInstanceMeta) (JobInstanceMeta, error) {
+		retryOptions := append(append([]JobOptionPreparer{}, jobOptions...), withoutEventSubscriptions)
+
+		var ji *JobInstance[T]
+		if previous.getJobOptions().StateStore != nil {
+			resumed, err := jd.newResumedJobInstance(ctx, previous.GetJobInstanceId(), input, retryOptions...)
+			if err != nil {
+				return nil, err
+			}
+			ji = resumed
+		} else {
+			ji = newJobInstance(jd, input, append(retryOptions, WithJobId(""))...)
+		}
+
+		retried := wrap(ji)
+		return r.run(ctx, jd, ji.getJobOptions(), func() JobInstanceMeta { return retried }, previous, retry)
+	}
+
+	return retry
+}
+
+// resolveJobId resolves options before the job instance is created, so the runner can look up the job id first.
+//
+//	returned option preparers pin the job id, in case it is generated.
+func resolveJobId(jobOptions []JobOptionPreparer) (*JobExecutionOptions, []JobOptionPreparer) {
+	options := newJobExecutionOptions(jobOptions...)
+	return options, append(jobOptions[:len(jobOptions):len(jobOptions)], WithJobId(options.Id))
+}
+
+func withoutEventSubscriptions(options *JobExecutionOptions) *JobExecutionOptions {
+	options.EventSubscriptions = nil
+	return options
+}
+
+// run admits and launches a job instance, returns the tracked job instance if job id exists.
+//
+//	newInstance is only called if the job id is not tracked yet, no job instance is created and discarded.
+func (r *JobRunner) run(ctx context.Context, jd JobDefinitionMeta, options *JobExecutionOptions, newInstance func() JobInstanceMeta, replacing JobInstanceMeta, retry jobRetryFunc) (JobInstanceMeta, error) {
+	ji, existing, previous, err := r.admit(jd, options, newInstance, replacing, retry)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		// subscriptions of this start follow the tracked job instance.
+		if err := existing.attachSubscriptions(options.EventSubscriptions); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	r.launch(ctx, ji, previous)
+	return ji, nil
+}
+
+// Get returns a tracked job instance by id
+func (r *JobRunner) Get(jobId string) (JobInstanceMeta, bool) {
+	r.EvictExpired()
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	ji, ok := r.instances[jobId]
+	return ji, ok
+}
+
+// List returns tracked job instances matching the filter, ordered by start time. nil filter returns all.
+func (r *JobRunner) List(filter *JobInstanceFilter) []JobInstanceMeta {
+	r.EvictExpired()
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]JobInstanceMeta, 0)
+	for _, ji := range r.instances {
+		if filter.match(ji) {
+			result = append(result, ji)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].StartTime().Equal(result[j].StartTime()) {
+			return result[i].GetJobInstanceId() < result[j].GetJobInstanceId()
+		}
+		return result[i].StartTime().Before(result[j].StartTime())
+	})
+
+	return result
+}
+
+// Cancel a tracked job instance by id, see JobInstance.Cancel
+func (r *JobRunner) Cancel(jobId string) error {
+	ji, ok := r.Get(jobId)
+	if !ok {
+		return ErrJobInstanceNotFound.WithMessage(fmt.Sprintf(MsgJobInstanceNotFound, jobId))
+	}
+
+	ji.Cancel()
+	return nil
+}
+
+// EvictExpired removes job instances finished longer than FinishedInstanceTTL, returns number of instances removed.
+//
+//	it is invoked by Get and List, you only need to call it to release memory eagerly.
+func (r *JobRunner) EvictExpired() int {
+	if r.options.FinishedInstanceTTL <= 0 {
+		return 0
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	evicted := 0
+	for jobId, ji := range r.instances {
+		if ji.GetState().IsTerminal() && time.Since(ji.EndTime()) > r.options.FinishedInstanceTTL {
+			delete(r.instances, jobId)
+			delete(r.retries, jobId)
+			evicted++
+		}
+	}
+
+	return evicted
+}
+
+func (r *JobRunner) checkRegistered(jd JobDefinitionMeta) error {
+	registered, ok := r.GetDefinition(jd.GetName())
+	if !ok || registered.getRootStep() != jd.getRootStep() {
+		return ErrJobDefinitionNotRegistered.WithMessage(fmt.Sprintf(MsgJobDefinitionNotRegistered, jd.GetName()))
+	}
+
+	return nil
+}
+
+// admit creates and tracks a job instance about to start.
+//
+//	returns the tracked job instance if the job id exists (unless it is the one replacing),
+//	or the new job instance, and job instances it should wait for, by concurrency key.
+func (r *JobRunner) admit(jd JobDefinitionMeta, options *JobExecutionOptions, newInstance func() JobInstanceMeta, replacing JobInstanceMeta, retry jobRetryFunc) (ji JobInstanceMeta, existing JobInstanceMeta, previous []JobInstanceMeta, err error) {
+	if key := options.ConcurrencyKey; key != "" && !options.ConcurrencyPolicy.valid() {
+		return nil, nil, nil, ErrUnknownConcurrencyPolicy.WithMessage(fmt.Sprintf(MsgUnknownConcurrencyPolicy, options.ConcurrencyPolicy, key))
+	}
+
+	r.EvictExpired()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	jobId := options.Id
+	if tracked, ok := r.instances[jobId]; ok && tracked != replacing {
+		if tracked.GetJobDefinition().getRootStep() != jd.getRootStep() {
+			return nil, nil, nil, ErrJobInstanceIdConflict.WithMessage(fmt.Sprintf(MsgJobInstanceIdConflict, jobId, tracked.GetJobDefinition().GetName()))
+		}
+		return nil, tracked, nil, nil
+	}
+
+	if key := options.ConcurrencyKey; key != "" {
+		for _, keyed := range r.concurrencyKeys[key] {
+			if !keyed.GetState().IsTerminal() {
+				previous = append(previous, keyed)
+			}
+		}
+
+		switch options.ConcurrencyPolicy {
+		case ConcurrencyPolicyQueue:
+		case ConcurrencyPolicyCancelPrevious:
+			for _, keyed := range previous {
+				keyed.Cancel()
+			}
+		default:
+			if len(previous) > 0 {
+				return nil, nil, nil, ErrConcurrencyKeyConflict.WithMessage(fmt.Sprintf(MsgConcurrencyKeyConflict, previous[0].GetJobInstanceId(), key))
+			}
+		}
+	}
+
+	ji = newInstance()
+	if key := options.ConcurrencyKey; key != "" {
+		r.concurrencyKeys[key] = append(previous, ji)
+		go r.releaseConcurrencyKey(key, ji)
+	}
+
+	r.instances[jobId] = ji
+	r.retries[jobId] = retry
+	return ji, nil, previous, nil
+}
+
+// releaseConcurrencyKey removes the job instance from the concurrency key once it finished, the key is dropped with its last job instance.
+func (r *JobRunner) releaseConcurrencyKey(key string, ji JobInstanceMeta) {
+	<-ji.Done()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	keyed := r.concurrencyKeys[key]
+	for i, k := range keyed {
+		if k == ji {
+			keyed = append(keyed[:i:i], keyed[i+1:]...)
+			break
+		}
+	}
+	if len(keyed) == 0 {
+		delete(r.concurrencyKeys, key)
+		return
+	}
+	r.concurrencyKeys[key] = keyed
+}
+
+// launch starts the job instance once previous job instances finished.
+func (r *JobRunner) launch(ctx context.Context, ji JobInstanceMeta, previous []JobInstanceMeta) {
+	// construction error is reported by the job instance.
+	if len(previous) == 0 {
+		_ = ji.start(ctx)
+		return
+	}
+
+	go func() {
+		for _, p := range previous {
+			select {
+			case <-p.Done():
+			case <-ctx.Done():
+				ji.abort(ctx.Err())
+				return
+			}
+		}
+		_ = ji.start(ctx)
+	}()
+}
@@ -0,0 +1,318 @@
+package asyncjob_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-asyncjob"
+	"github.com/Azure/go-asynctask"
+	"github.com/stretchr/testify/assert"
+)
+
+func newGatedJob(name string) (*asyncjob.JobDefinition[chan struct{}], error) {
+	job := asyncjob.NewJobDefinition[chan struct{}](name)
+	_, err := asyncjob.AddStep(job, "WaitForGate", func(gate chan struct{}) asynctask.AsyncFunc[interface{}] {
+		return func(ctx context.Context) (interface{}, error) {
+			select {
+			case <-gate:
+				return nil, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	})
+	return job, err
+}
+
+func TestJobRunner(t *testing.T) {
+	t.Parallel()
+
+	runner := asyncjob.NewJobRunner()
+	jobA, err := newGatedJob("jobA")
+	assert.NoError(t, err)
+	jobB, err := newGatedJob("jobB")
+	assert.NoError(t, err)
+	anotherJobA, err := newGatedJob("jobA")
+	assert.NoError(t, err)
+
+	_, err = asyncjob.StartJob(context.Background(), runner, jobA, make(chan struct{}))
+	assert.True(t, errors.Is(err, asyncjob.ErrJobDefinitionNotRegistered))
+
+	assert.NoError(t, runner.Register(jobA))
+	assert.NoError(t, runner.Register(jobA))
+	assert.NoError(t, runner.Register(jobB))
+	err = runner.Register(anotherJobA)
+	assert.True(t, errors.Is(err, asyncjob.ErrDuplicateJobDefinition))
+	_, err = asyncjob.StartJob(context.Background(), runner, anotherJobA, make(chan struct{}))
+	assert.True(t, errors.Is(err, asyncjob.ErrJobDefinitionNotRegistered))
+
+	registered, ok := runner.GetDefinition("jobA")
+	assert.True(t, ok)
+	assert.Equal(t, jobA, registered)
+
+	beforeStart := time.Now()
+	gate1 := make(chan struct{})
+	instance1, err := asyncjob.StartJob(context.Background(), runner, jobA, gate1, asyncjob.WithJobId("a1"))
+	assert.NoError(t, err)
+	instance2, err := asyncjob.StartJob(context.Background(), runner, jobA, make(chan struct{}), asyncjob.WithJobId("a2"))
+	assert.NoError(t, err)
+	instance3, err := asyncjob.StartJob(context.Background(), runner, jobB, make(chan struct{}), asyncjob.WithJobId("b1"))
+	assert.NoError(t, err)
+
+	found, ok := runner.Get("a1")
+	assert.True(t, ok)
+	assert.Equal(t, instance1, found)
+	_, ok = runner.Get("notExists")
+	assert.False(t, ok)
+
+	assert.Len(t, runner.List(nil), 3)
+	assert.Len(t, runner.List(&asyncjob.JobInstanceFilter{DefinitionName: "jobA"}), 2)
+	assert.Len(t, runner.List(&asyncjob.JobInstanceFilter{StartedAfter: beforeStart}), 3)
+	assert.Len(t, runner.List(&asyncjob.JobInstanceFilter{StartedBefore: beforeStart}), 0)
+
+	// finish a1, cancel a2
+	close(gate1)
+	<-instance1.Done()
+	assert.NoError(t, runner.Cancel("a2"))
+	<-instance2.Done()
+	assert.Equal(t, asyncjob.JobStateCanceled, instance2.GetState())
+	err = runner.Cancel("notExists")
+	assert.True(t, errors.Is(err, asyncjob.ErrJobInstanceNotFound))
+
+	completed := runner.List(&asyncjob.JobInstanceFilter{States: []asyncjob.JobState{asyncjob.JobStateCompleted}})
+	assert.Len(t, completed, 1)
+	assert.Equal(t, "a1", completed[0].GetJobInstanceId())
+	running := runner.List(&asyncjob.JobInstanceFilter{States: []asyncjob.JobState{asyncjob.JobStateRunning}})
+	assert.Len(t, running, 1)
+	assert.Equal(t, "b1", running[0].GetJobInstanceId())
+
+	// no TTL, nothing evicted
+	assert.Equal(t, 0, runner.EvictExpired())
+
+	instance3.Cancel()
+	<-instance3.Done()
+}
+
+func TestJobRunnerEviction(t *testing.T) {
+	t.Parallel()
+
+	runner := asyncjob.NewJobRunner(asyncjob.WithFinishedInstanceTTL(10 * time.Millisecond))
+	jobA, err := newGatedJob("jobA")
+	assert.NoError(t, err)
+	assert.NoError(t, runner.Register(jobA))
+
+	gate := make(chan struct{})
+	finished, err := asyncjob.StartJob(context.Background(), runner, jobA, gate, asyncjob.WithJobId("finished"))
+	assert.NoError(t, err)
+	running, err := asyncjob.StartJob(context.Background(), runner, jobA, make(chan struct{}), asyncjob.WithJobId("running"))
+	assert.NoError(t, err)
+
+	close(gate)
+	<-finished.Done()
+	_, ok := runner.Get("finished")
+	assert.True(t, ok)
+
+	time.Sleep(20 * time.Millisecond)
+	_, ok = runner.Get("finished")
+	assert.False(t, ok)
+	_, ok = runner.Get("running")
+	assert.True(t, ok)
+
+	running.Cancel()
+	<-running.Done()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, runner.EvictExpired())
+	assert.Empty(t, runner.List(nil))
+}
+
+func TestJobRunnerWithResult(t *testing.T) {
+	t.Parallel()
+
+	runner := asyncjob.NewJobRunner()
+	assert.NoError(t, runner.Register(SqlSummaryAsyncJobDefinition))
+
+	ctx := context.WithValue(context.Background(), testLoggingContextKey, t)
+	jobInstance, err := asyncjob.StartJobWithResult(ctx, runner, SqlSummaryAsyncJobDefinition, NewSqlJobLib(&SqlSummaryJobParameters{
+		ServerName: "server1",
+		Table1:     "table1",
+		Query1:     "query1",
+		Table2:     "table2",
+		Query2:     "query2",
+	}), asyncjob.WithJobId("withResult"))
+	assert.NoError(t, err)
+
+	result, err := jobInstance.Result(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "table1", result.QueryResult1["tableName"])
+
+	found, ok := runner.Get("withResult")
+	assert.True(t, ok)
+	assert.Equal(t, "sqlSummaryJob", found.GetJobDefinition().GetName())
+}
+
+func TestJobRunnerIdempotentStart(t *testing.T) {
+	t.Parallel()
+
+	runner := asyncjob.NewJobRunner()
+	jobA, err := newGatedJob("jobA")
+	assert.NoError(t, err)
+	jobB, err := newGatedJob("jobB")
+	assert.NoError(t, err)
+	assert.NoError(t, runner.Register(jobA))
+	assert.NoError(t, runner.Register(jobB))
+
+	gate := make(chan struct{})
+	instance1, err := asyncjob.StartJob(context.Background(), runner, jobA, gate, asyncjob.WithJobId("same"))
+	assert.NoError(t, err)
+	// subscription of a repeated start follows the tracked job instance.
+	subscription := asyncjob.NewEventSubscription()
+	instance2, err := asyncjob.StartJob(context.Background(), runner, jobA, make(chan struct{}), asyncjob.WithJobId("same"), asyncjob.WithEventSubscription(subscription))
+	assert.NoError(t, err)
+	assert.Same(t, instance1, instance2)
+	assert.Len(t, runner.List(nil), 1)
+
+	_, err = asyncjob.StartJob(context.Background(), runner, jobB, make(chan struct{}), asyncjob.WithJobId("same"))
+	assert.True(t, errors.Is(err, asyncjob.ErrJobInstanceIdConflict))
+
+	close(gate)
+	assert.NoError(t, instance1.Wait(context.Background()))
+	var last asyncjob.JobEvent
+	for event := range subscription.Events() {
+		assert.Equal(t, "same", event.JobInstanceId)
+		last = event
+	}
+	assert.Equal(t, asyncjob.EventJobCompleted, last.Type)
+
+	// finished job instance is still returned
+	instance3, err := asyncjob.StartJob(context.Background(), runner, jobA, make(chan struct{}), asyncjob.WithJobId("same"))
+	assert.NoError(t, err)
+	assert.Same(t, instance1, instance3)
+}
+
+func TestJobRunnerConcurrencyKey(t *testing.T) {
+	t.Parallel()
+
+	runner := asyncjob.NewJobRunner()
+	jobA, err := newGatedJob("jobA")
+	assert.NoError(t, err)
+	assert.NoError(t, runner.Register(jobA))
+	ctx := context.Background()
+
+	// reject
+	gate1 := make(chan struct{})
+	first, err := asyncjob.StartJob(ctx, runner, jobA, gate1, asyncjob.WithConcurrencyKey("key", asyncjob.ConcurrencyPolicyReject))
+	assert.NoError(t, err)
+	_, err = asyncjob.StartJob(ctx, runner, jobA, make(chan struct{}), asyncjob.WithConcurrencyKey("key", asyncjob.ConcurrencyPolicyReject))
+	assert.True(t, errors.Is(err, asyncjob.ErrConcurrencyKeyConflict))
+	other, err := asyncjob.StartJob(ctx, runner, jobA, make(chan struct{}), asyncjob.WithConcurrencyKey("otherKey", asyncjob.ConcurrencyPolicyReject))
+	assert.NoError(t, err)
+	other.Cancel()
+
+	// queue
+	gate2 := make(chan struct{})
+	queued, err := asyncjob.StartJob(ctx, runner, jobA, gate2, asyncjob.WithConcurrencyKey("key", asyncjob.ConcurrencyPolicyQueue))
+	assert.NoError(t, err)
+	assert.Equal(t, asyncjob.JobStatePending, queued.GetState())
+	canceledInQueue, err := asyncjob.StartJob(ctx, runner, jobA, make(chan struct{}), asyncjob.WithConcurrencyKey("key", asyncjob.ConcurrencyPolicyQueue))
+	assert.NoError(t, err)
+	canceledInQueue.Cancel()
+	<-canceledInQueue.Done()
+	assert.Equal(t, asyncjob.JobStateCanceled, canceledInQueue.GetState())
+
+	close(gate1)
+	assert.NoError(t, first.Wait(ctx))
+	assert.Eventually(t, func() bool { return queued.GetState() == asyncjob.JobStateRunning }, time.Second, time.Millisecond)
+
+	// cancel previous
+	latest, err := asyncjob.StartJob(ctx, runner, jobA, make(chan struct{}), asyncjob.WithConcurrencyKey("key", asyncjob.ConcurrencyPolicyCancelPrevious))
+	assert.NoError(t, err)
+	<-queued.Done()
+	assert.Equal(t, asyncjob.JobStateCanceled, queued.GetState())
+	assert.Eventually(t, func() bool { return latest.GetState() == asyncjob.JobStateRunning }, time.Second, time.Millisecond)
+	latest.Cancel()
+	<-latest.Done()
+	close(gate2)
+
+	// queued job instance is aborted if start context is canceled
+	blocker, err := asyncjob.StartJob(ctx, runner, jobA, make(chan struct{}), asyncjob.WithConcurrencyKey("key2", asyncjob.ConcurrencyPolicyQueue))
+	assert.NoError(t, err)
+	startCtx, cancelStart := context.WithCancel(ctx)
+	aborted, err := asyncjob.StartJob(startCtx, runner, jobA, make(chan struct{}), asyncjob.WithConcurrencyKey("key2", asyncjob.ConcurrencyPolicyQueue))
+	assert.NoError(t, err)
+	cancelStart()
+	assert.ErrorIs(t, aborted.Wait(ctx), context.Canceled)
+	blocker.Cancel()
+	<-blocker.Done()
+
+	// cancel racing with the start of a queued job instance, it never runs after canceled.
+	for i := 0; i < 20; i++ {
+		gate := make(chan struct{})
+		holder, err := asyncjob.StartJob(ctx, runner, jobA, gate, asyncjob.WithConcurrencyKey("key3", asyncjob.ConcurrencyPolicyQueue))
+		assert.NoError(t, err)
+		racing, err := asyncjob.StartJob(ctx, runner, jobA, make(chan struct{}), asyncjob.WithConcurrencyKey("key3", asyncjob.ConcurrencyPolicyQueue))
+		assert.NoError(t, err)
+		go close(gate)
+		racing.Cancel()
+		assert.ErrorIs(t, racing.Wait(ctx), context.Canceled)
+		assert.NoError(t, holder.Wait(ctx))
+	}
+
+	_, err = asyncjob.StartJob(ctx, runner, jobA, make(chan struct{}), asyncjob.WithConcurrencyKey("key", "Unknown"))
+	assert.ErrorIs(t, err, asyncjob.ErrUnknownConcurrencyPolicy)
+}
+
+func TestJobRunnerRetry(t *testing.T) {
+	t.Parallel()
+
+	runner := asyncjob.NewJobRunner()
+	assert.NoError(t, runner.Register(SqlSummaryAsyncJobDefinition))
+	store := asyncjob.NewInMemoryStateStore()
+	shouldFail := atomic.Bool{}
+	shouldFail.Store(true)
+
+	params := &SqlSummaryJobParameters{
+		ServerName: "server1",
+		Table1:     "table1",
+		Query1:     "query1",
+		Table2:     "table2",
+		Query2:     "query2",
+		ErrorInjection: map[string]func() error{
+			"GetTableClient.server1.table2": func() error {
+				if shouldFail.Load() {
+					return fmt.Errorf("table2 not exists")
+				}
+				return nil
+			},
+		},
+	}
+	ctx := context.WithValue(context.Background(), testLoggingContextKey, t)
+	failed, err := asyncjob.StartJobWithResult(ctx, runner, SqlSummaryAsyncJobDefinition, NewSqlJobLib(params), asyncjob.WithJobId("retryJob"), asyncjob.WithStateStore(store))
+	assert.NoError(t, err)
+	assert.Error(t, failed.Wait(context.Background()))
+
+	_, err = runner.Retry(ctx, "notExists")
+	assert.True(t, errors.Is(err, asyncjob.ErrJobInstanceNotFound))
+
+	// with StateStore, job instance is resumed with same id
+	shouldFail.Store(false)
+	retried, err := runner.Retry(ctx, "retryJob")
+	assert.NoError(t, err)
+	assert.Equal(t, "retryJob", retried.GetJobInstanceId())
+	assert.NoError(t, retried.Wait(context.Background()))
+
+	found, ok := runner.Get("retryJob")
+	assert.True(t, ok)
+	assert.Equal(t, retried, found)
+	assert.NotEqual(t, failed, found)
+	restored, ok := found.GetStepInstance("GetConnection")
+	assert.True(t, ok)
+	assert.True(t, restored.(*asyncjob.StepInstance[*SqlConnection]).Restored())
+
+	result, err := found.(*asyncjob.JobInstanceWithResult[*SqlSummaryJobLib, *SummarizedResult]).Result(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "table1", result.QueryResult1["tableName"])
+}
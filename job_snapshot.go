@@ -0,0 +1,81 @@
+package asyncjob
+
+import (
+	"sort"
+	"time"
+)
+
+// JobInstanceSnapshot is a point-in-time view of a job instance, it can be marshaled to JSON directly.
+type JobInstanceSnapshot struct {
+	JobInstanceId  string                  `json:"jobInstanceId"`
+	DefinitionName string                  `json:"definitionName"`
+	State          JobState                `json:"state"`
+	StartTime      *time.Time              `json:"startTime,omitempty"`
+	EndTime        *time.Time              `json:"endTime,omitempty"`
+	Steps          []*StepInstanceSnapshot `json:"steps"`
+}
+
+// StepInstanceSnapshot is a point-in-time view of a step instance.
+type StepInstanceSnapshot struct {
+	Name      string     `json:"name"`
+	State     StepState  `json:"state"`
+	StartTime *time.Time `json:"startTime,omitempty"`
+	// Duration in nanoseconds
+	Duration  time.Duration `json:"duration"`
+	Retries   uint          `json:"retries"`
+	Error     string        `json:"error,omitempty"`
+	DependsOn []string      `json:"dependsOn"`
+	// Restored is true if step output is restored from StateStore instead of executed.
+	Restored bool `json:"restored,omitempty"`
+}
+
+// Snapshot returns current state of the job instance and all its steps.
+//
+//	steps are ordered by name, so the marshaled JSON is stable.
+func (ji *JobInstance[T]) Snapshot() *JobInstanceSnapshot {
+	steps := ji.getSteps()
+	snapshot := &JobInstanceSnapshot{
+		JobInstanceId:  ji.GetJobInstanceId(),
+		DefinitionName: ji.Definition.GetName(),
+		State:          ji.GetState(),
+		Steps:          make([]*StepInstanceSnapshot, 0, len(steps)),
+	}
+
+	if startTime := ji.StartTime(); !startTime.IsZero() {
+		snapshot.StartTime = &startTime
+	}
+	if endTime := ji.EndTime(); !endTime.IsZero() {
+		snapshot.EndTime = &endTime
+	}
+
+	for _, step := range steps {
+		snapshot.Steps = append(snapshot.Steps, snapshotStep(step))
+	}
+	sort.Slice(snapshot.Steps, func(i, j int) bool {
+		return snapshot.Steps[i].Name < snapshot.Steps[j].Name
+	})
+
+	return snapshot
+}
+
+func newStepInstanceSnapshot(name string, dependsOn []string, data StepExecutionData, state StepState, err error, restored bool) *StepInstanceSnapshot {
+	stepSnapshot := &StepInstanceSnapshot{
+		Name:      name,
+		State:     state,
+		Duration:  data.Duration,
+		DependsOn: append([]string{}, dependsOn...),
+		Restored:  restored,
+	}
+	if !data.StartTime.IsZero() {
+		startTime := data.StartTime
+		stepSnapshot.StartTime = &startTime
+	}
+	if data.Retried != nil {
+		stepSnapshot.Retries = data.Retried.Count
+	}
+	if err != nil {
+		stepSnapshot.Error = err.Error()
+	}
+
+	return stepSnapshot
+}
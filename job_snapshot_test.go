@@ -0,0 +1,88 @@
+package asyncjob_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Azure/go-asyncjob"
+	"github.com/Azure/go-asyncjob/graph"
+	"github.com/Azure/go-asynctask"
+	"github.com/stretchr/testify/assert"
+)
+
+// StepInstanceMeta can be implemented outside of the package, like a mock in tests.
+var _ asyncjob.StepInstanceMeta = fakeStepInstance{}
+
+type fakeStepInstance struct{}
+
+func (fakeStepInstance) GetName() string { return "fake" }
+func (fakeStepInstance) ExecutionData() *asyncjob.StepExecutionData {
+	return &asyncjob.StepExecutionData{}
+}
+func (fakeStepInstance) GetState() asyncjob.StepState                   { return asyncjob.StepStatePending }
+func (fakeStepInstance) GetJobInstance() asyncjob.JobInstanceMeta       { return nil }
+func (fakeStepInstance) GetStepDefinition() asyncjob.StepDefinitionMeta { return nil }
+func (fakeStepInstance) GetError() error                                { return nil }
+func (fakeStepInstance) Waitable() asynctask.Waitable                   { return nil }
+func (fakeStepInstance) DotSpec() *graph.DotNodeSpec                    { return &graph.DotNodeSpec{Name: "fake"} }
+
+func TestJobSnapshot(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.WithValue(context.Background(), testLoggingContextKey, t)
+	jobInstance := SqlSummaryAsyncJobDefinition.Start(ctx, NewSqlJobLib(&SqlSummaryJobParameters{
+		ServerName: "server1",
+		Table1:     "table1",
+		Query1:     "query1",
+		Table2:     "table2",
+		Query2:     "query2",
+		ErrorInjection: map[string]func() error{
+			"GetTableClient.server1.table1": func() error { return fmt.Errorf("table1 not exists") },
+		},
+	}), asyncjob.WithJobId("snapshotJob"))
+
+	<-jobInstance.Done()
+
+	snapshot := jobInstance.Snapshot()
+	assert.Equal(t, "snapshotJob", snapshot.JobInstanceId)
+	assert.Equal(t, "sqlSummaryJob", snapshot.DefinitionName)
+	assert.Equal(t, asyncjob.JobStateFailed, snapshot.State)
+	assert.NotNil(t, snapshot.StartTime)
+	assert.NotNil(t, snapshot.EndTime)
+	assert.Len(t, snapshot.Steps, 9)
+
+	steps := map[string]*asyncjob.StepInstanceSnapshot{}
+	for _, step := range snapshot.Steps {
+		steps[step.Name] = step
+	}
+
+	assert.Equal(t, asyncjob.StepStateCompleted, steps["GetConnection"].State)
+	assert.NotNil(t, steps["GetConnection"].StartTime)
+	assert.Equal(t, []string{"sqlSummaryJob"}, steps["GetConnection"].DependsOn)
+
+	assert.Equal(t, asyncjob.StepStateFailed, steps["GetTableClient1"].State)
+	assert.Equal(t, "step \"GetTableClient1\" failed: table1 not exists", steps["GetTableClient1"].Error)
+
+	assert.Equal(t, asyncjob.StepStateBlocked, steps["QueryTable1"].State)
+	assert.Equal(t, "PrecedentStepFailed: step \"QueryTable1\" is blocked, precedent step \"GetTableClient1\" failed", steps["QueryTable1"].Error)
+	assert.Nil(t, steps["QueryTable1"].StartTime)
+	assert.Equal(t, []string{"CheckAuth", "GetTableClient1"}, steps["QueryTable1"].DependsOn)
+
+	stepInstance, ok := jobInstance.GetStepInstance("GetTableClient1")
+	assert.True(t, ok)
+	assert.Error(t, stepInstance.GetError())
+
+	// marshaled JSON should be stable
+	jsonBytes, err := json.Marshal(snapshot)
+	assert.NoError(t, err)
+	jsonBytes2, err := json.Marshal(jobInstance.Snapshot())
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonBytes), string(jsonBytes2))
+
+	unmarshaled := &asyncjob.JobInstanceSnapshot{}
+	assert.NoError(t, json.Unmarshal(jsonBytes, unmarshaled))
+	assert.Equal(t, snapshot.Steps[0].Name, unmarshaled.Steps[0].Name)
+	assert.Contains(t, string(jsonBytes), `"state":"failed"`)
+}
@@ -2,12 +2,18 @@ package asyncjob_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/Azure/go-asyncjob"
+	"github.com/Azure/go-asyncjob/graph"
+	"github.com/Azure/go-asynctask"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -93,6 +99,24 @@ func TestJobError(t *testing.T) {
 	assert.Equal(t, "GetTableClient1", jobErr.StepInstance.GetName())
 }
 
+func TestVisualizeStepError(t *testing.T) {
+	t.Parallel()
+
+	job := asyncjob.NewJobDefinition[string]("stepErrorJob")
+	_, err := asyncjob.AddStepWithStaticFunc(job, "Fail", func(ctx context.Context) (string, error) {
+		return "", fmt.Errorf("table \"t1\" not exists\nretry later")
+	})
+	assert.NoError(t, err)
+
+	jobInstance := job.Start(context.Background(), "input")
+	assert.Error(t, jobInstance.Wait(context.Background()))
+
+	// error message is escaped in the tooltip
+	dotGraph, err := jobInstance.Visualize()
+	assert.NoError(t, err)
+	assert.Contains(t, dotGraph, `\nError: step \"Fail\" failed: table \"t1\" not exists\nretry later"`)
+}
+
 func TestJobPanic(t *testing.T) {
 	t.Parallel()
 
@@ -113,8 +137,120 @@ func TestJobPanic(t *testing.T) {
 
 	jobErr := &asyncjob.JobError{}
 	assert.True(t, errors.As(err, &jobErr))
-	assert.Equal(t, jobErr.Code, asyncjob.ErrStepFailed)
+	assert.Equal(t, jobErr.Code, asyncjob.ErrStepPanicked)
 	assert.Equal(t, jobErr.StepInstance.GetName(), "GetTableClient2")
+
+	panicErr := &asyncjob.PanicError{}
+	assert.True(t, errors.As(err, &panicErr))
+	assert.NotEmpty(t, panicErr.Stack)
+	assert.Contains(t, err.Error(), "step \"GetTableClient2\" panicked: panic caught: ")
+}
+
+func TestJobPanicPolicy(t *testing.T) {
+	t.Parallel()
+
+	job := asyncjob.NewJobDefinition[string]("panicJob")
+	_, err := asyncjob.AddStepWithStaticFunc(job, "Panic", func(ctx context.Context) (string, error) {
+		panic(fmt.Errorf("step blew up"))
+	}, asyncjob.WithPanicPolicy(asyncjob.PanicPolicyRepanic))
+	assert.NoError(t, err)
+
+	jobInstance := job.Start(context.Background(), "input")
+	<-jobInstance.Done()
+	assert.Panics(t, func() { jobInstance.Wait(context.Background()) })
+	panicErr := &asyncjob.PanicError{}
+	assert.True(t, errors.As(jobInstance.Err(), &panicErr))
+	assert.EqualError(t, panicErr.Value.(error), "step blew up")
+
+	// panic is not retried, and Result re-panics as well
+	var attempts int32
+	retryPanicJob := asyncjob.NewJobDefinition[string]("retryPanicJob")
+	retryPanicStep, err := asyncjob.AddStepWithStaticFunc(retryPanicJob, "Panic", func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&attempts, 1)
+		panic("step blew up")
+	}, asyncjob.WithPanicPolicy(asyncjob.PanicPolicyRepanic), asyncjob.WithRetry(newLinearRetryPolicy(time.Millisecond, 3)))
+	assert.NoError(t, err)
+	retryPanicJobWithResult, err := asyncjob.JobWithResult(retryPanicJob, retryPanicStep)
+	assert.NoError(t, err)
+
+	resultInstance := retryPanicJobWithResult.Start(context.Background(), "input")
+	<-resultInstance.Done()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	assert.Panics(t, func() { resultInstance.Result(context.Background()) })
+
+	// panic in ContextPolicy is surfaced, not only printed
+	contextPanicJob := asyncjob.NewJobDefinition[string]("contextPanicJob")
+	_, err = asyncjob.AddStepWithStaticFunc(contextPanicJob, "Step", func(ctx context.Context) (string, error) {
+		return "done", nil
+	}, asyncjob.WithContextEnrichment(func(ctx context.Context, _ asyncjob.StepInstanceMeta) context.Context {
+		panic("enrichment blew up")
+	}))
+	assert.NoError(t, err)
+
+	err = contextPanicJob.Start(context.Background(), "input").Wait(context.Background())
+	jobErr := &asyncjob.JobError{}
+	assert.True(t, errors.As(err, &jobErr))
+	assert.Equal(t, asyncjob.ErrStepPanicked, jobErr.Code)
+	panicErr = &asyncjob.PanicError{}
+	assert.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "enrichment blew up", panicErr.Value)
+}
+
+func TestJobStartE(t *testing.T) {
+	t.Parallel()
+
+	job := asyncjob.NewJobDefinition[string]("startEJob")
+	parent, err := asyncjob.AddStepWithStaticFunc(job, "Parent", func(ctx context.Context) (string, error) {
+		return "parent", nil
+	})
+	assert.NoError(t, err)
+
+	// a step of another job definition with same name is refused, instead of failing type assertion at runtime.
+	otherJob := asyncjob.NewJobDefinition[string]("otherJob")
+	otherParent, err := asyncjob.AddStepWithStaticFunc(otherJob, "Parent", func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	assert.NoError(t, err)
+
+	_, err = asyncjob.StepAfterWithStaticFunc(job, "Child", otherParent, func(ctx context.Context, i int) (int, error) {
+		return i + 1, nil
+	})
+	assert.ErrorIs(t, err, asyncjob.ErrRefStepNotInJob)
+	_, err = asyncjob.AddStepWithStaticFunc(job, "Sibling", func(ctx context.Context) (string, error) {
+		return "sibling", nil
+	})
+	assert.NoError(t, err)
+	otherSibling, err := asyncjob.AddStepWithStaticFunc(otherJob, "Sibling", func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	assert.NoError(t, err)
+	_, err = asyncjob.StepAfterBothWithStaticFunc(job, "Child", parent, otherSibling, func(ctx context.Context, s string, i int) (int, error) {
+		return i + 1, nil
+	})
+	assert.ErrorIs(t, err, asyncjob.ErrRefStepNotInJob)
+	_, ok := job.GetStep("Child")
+	assert.False(t, ok)
+
+	_, err = asyncjob.StepAfterWithStaticFunc(job, "Child", parent, func(ctx context.Context, s string) (string, error) {
+		return s + "child", nil
+	})
+	assert.NoError(t, err)
+
+	jobInstance, err := job.StartE(context.Background(), "input")
+	assert.NoError(t, err)
+	assert.NoError(t, jobInstance.Wait(context.Background()))
+
+	// construction error is returned by StartE, and by Wait when started with Start.
+	subscription := jobInstance.Subscribe()
+	jobInstance, err = job.StartE(context.Background(), "input", asyncjob.WithEventSubscription(subscription))
+	assert.Nil(t, jobInstance)
+	assert.ErrorIs(t, err, asyncjob.ErrSubscriptionAttached)
+	messageErr := &asyncjob.MessageError{}
+	assert.True(t, errors.As(err, &messageErr))
+
+	startedInstance := job.Start(context.Background(), "input", asyncjob.WithEventSubscription(subscription))
+	assert.ErrorIs(t, startedInstance.Wait(context.Background()), asyncjob.ErrSubscriptionAttached)
+	assert.Equal(t, asyncjob.JobStateFailed, startedInstance.GetState())
 }
 
 func TestJobStepRetryStepAfter(t *testing.T) {
@@ -311,3 +447,525 @@ func renderGraph(t *testing.T, jb GraphRender) {
 type GraphRender interface {
 	Visualize() (string, error)
 }
+
+func TestJobState(t *testing.T) {
+	t.Parallel()
+
+	gate := make(chan struct{})
+	job := asyncjob.NewJobDefinition[string]("stateJob")
+	_, err := asyncjob.AddStepWithStaticFunc(job, "WaitForGate", func(ctx context.Context) (string, error) {
+		select {
+		case <-gate:
+			return "opened", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+	assert.NoError(t, err)
+
+	jobInstance := job.Start(context.Background(), "input")
+	assert.Equal(t, asyncjob.JobStateRunning, jobInstance.GetState())
+	assert.False(t, jobInstance.StartTime().IsZero())
+	assert.True(t, jobInstance.EndTime().IsZero())
+	assert.NoError(t, jobInstance.Err())
+	select {
+	case <-jobInstance.Done():
+		assert.Fail(t, "job should not be done yet")
+	default:
+	}
+
+	close(gate)
+	select {
+	case <-jobInstance.Done():
+	case <-time.After(time.Second):
+		assert.Fail(t, "job should be done")
+	}
+	assert.Equal(t, asyncjob.JobStateCompleted, jobInstance.GetState())
+	assert.True(t, jobInstance.GetState().IsTerminal())
+	assert.False(t, jobInstance.EndTime().Before(jobInstance.StartTime()))
+	assert.NoError(t, jobInstance.Err())
+
+	// cancel the job through context
+	ctx, cancel := context.WithCancel(context.Background())
+	canceledInstance := job.Start(ctx, "input")
+	cancel()
+	<-canceledInstance.Done()
+	assert.Equal(t, asyncjob.JobStateCanceled, canceledInstance.GetState())
+	assert.ErrorIs(t, canceledInstance.Err(), context.Canceled)
+}
+
+func TestVisualizeAs(t *testing.T) {
+	t.Parallel()
+
+	jd, err := BuildJob(map[string]asyncjob.RetryPolicy{})
+	assert.NoError(t, err)
+
+	mermaid, err := jd.VisualizeAs(asyncjob.VisualizeFormatMermaid)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(mermaid, "flowchart TD\n"))
+	assert.Contains(t, mermaid, `["QueryTable1"]:::s_gray`)
+
+	dot, err := jd.VisualizeAs(asyncjob.VisualizeFormatDOT)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dot, "digraph {"))
+
+	// output is stable across definitions built same way
+	jd2, err := BuildJob(map[string]asyncjob.RetryPolicy{})
+	assert.NoError(t, err)
+	dot2, err := jd2.Visualize()
+	assert.NoError(t, err)
+	assert.Equal(t, dot, dot2)
+
+	_, err = jd.VisualizeAs("svg")
+	assert.True(t, errors.Is(err, asyncjob.ErrUnsupportedVisualizeFormat))
+
+	jobInstance := jd.Start(context.Background(), NewSqlJobLib(&SqlSummaryJobParameters{
+		ServerName: "server1",
+		Table1:     "table1",
+		Query1:     "query1",
+		Table2:     "table2",
+		Query2:     "query2",
+	}))
+	assert.NoError(t, jobInstance.Wait(context.Background()))
+
+	mermaid, err = jobInstance.VisualizeAs(asyncjob.VisualizeFormatMermaid)
+	assert.NoError(t, err)
+	t.Log(mermaid)
+	assert.Contains(t, mermaid, `{{"QueryTable1"}}:::s_green`)
+	assert.Contains(t, mermaid, `[/"sqlSummaryJob"\]:::s_green`)
+	assert.Contains(t, mermaid, "classDef s_green fill:green")
+	assert.Contains(t, mermaid, "stroke:green,stroke-width:2px")
+}
+
+func TestRenderJob(t *testing.T) {
+	t.Parallel()
+
+	jd, err := BuildJob(map[string]asyncjob.RetryPolicy{})
+	assert.NoError(t, err)
+
+	jsonGraph, err := jd.VisualizeAs(asyncjob.VisualizeFormatJSON)
+	assert.NoError(t, err)
+	model := &graph.RenderGraph{}
+	assert.NoError(t, json.Unmarshal([]byte(jsonGraph), model))
+	assert.Len(t, model.Nodes, 9)
+	assert.Len(t, model.Edges, 11)
+
+	tmpl := template.Must(template.New("edges").Parse(`{{range .Edges}}{{if eq .To "Summarize"}}{{.From}};{{end}}{{end}}`))
+	buf := &strings.Builder{}
+	assert.NoError(t, jd.Render(buf, graph.TemplateRenderer{Template: tmpl}))
+	assert.Equal(t, "QueryTable1;QueryTable2;", buf.String())
+}
+
+func TestJobDefinitionStepOrder(t *testing.T) {
+	t.Parallel()
+
+	nodeNames := func(jsonGraph string) []string {
+		model := &graph.RenderGraph{}
+		assert.NoError(t, json.Unmarshal([]byte(jsonGraph), model))
+		names := make([]string, 0, len(model.Nodes))
+		for _, node := range model.Nodes {
+			names = append(names, node.Name)
+		}
+		return names
+	}
+
+	jd := asyncjob.NewJobDefinition[string]("orderJob", asyncjob.WithStepOrder(graph.WithOrderByName()))
+	for _, name := range []string{"c", "a", "b"} {
+		_, err := asyncjob.AddStepWithStaticFunc(jd, name, func(ctx context.Context) (string, error) { return "", nil })
+		assert.NoError(t, err)
+	}
+
+	jsonGraph, err := jd.VisualizeAs(asyncjob.VisualizeFormatJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "orderJob"}, nodeNames(jsonGraph))
+
+	// job instance graph shares the order rule
+	jobInstance := jd.Start(context.Background(), "input")
+	assert.NoError(t, jobInstance.Wait(context.Background()))
+	jsonGraph, err = jobInstance.VisualizeAs(asyncjob.VisualizeFormatJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "orderJob"}, nodeNames(jsonGraph))
+
+	// missing order rule keeps insertion order
+	for _, order := range []graph.GraphOptionPreparer{nil, graph.WithOrderByPriority(nil)} {
+		jd := asyncjob.NewJobDefinition[string]("insertionJob", asyncjob.WithStepOrder(order))
+		for _, name := range []string{"c", "a"} {
+			_, err := asyncjob.AddStepWithStaticFunc(jd, name, func(ctx context.Context) (string, error) { return "", nil })
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, jd.Start(context.Background(), "input").Wait(context.Background()))
+		jsonGraph, err := jd.VisualizeAs(asyncjob.VisualizeFormatJSON)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"insertionJob", "c", "a"}, nodeNames(jsonGraph))
+	}
+}
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	explain := SqlSummaryAsyncJobDefinition.Explain()
+	t.Log(explain)
+	lines := strings.Split(strings.TrimSpace(explain), "\n")
+	assert.Regexp(t, `^job sqlSummaryJob: \d+ steps in \d+ stages, max parallelism 2$`, lines[0])
+	assert.Equal(t, "input: sqlSummaryJob", lines[1])
+	assert.Equal(t, "stage 1 (2 steps, in parallel):", lines[2])
+	assert.Equal(t, "  - GetConnection", lines[3])
+	assert.NotContains(t, strings.Join(lines[2:], "\n"), "sqlSummaryJob")
+
+	// root step is not counted
+	job := asyncjob.NewJobDefinition[string]("explainJob")
+	for _, name := range []string{"a", "b"} {
+		_, err := asyncjob.AddStepWithStaticFunc(job, name, func(ctx context.Context) (string, error) { return "", nil })
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "job explainJob: 2 steps in 1 stages, max parallelism 2\ninput: explainJob\nstage 1 (2 steps, in parallel):\n  - a\n  - b\n", job.Explain())
+	assert.Contains(t, explain, "  - Summarize <- [QueryTable1, QueryTable2]")
+
+	dot, err := SqlSummaryAsyncJobDefinition.VisualizeAs(asyncjob.VisualizeFormatDOT)
+	assert.NoError(t, err)
+	assert.NotContains(t, dot, `rank = "same"`)
+	buf := &strings.Builder{}
+	assert.NoError(t, SqlSummaryAsyncJobDefinition.Render(buf, graph.DotRenderer{RankByLevel: true}))
+	assert.Contains(t, buf.String(), `{ rank = "same"; "GetTableClient1"; "GetTableClient2"; }`)
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	job := asyncjob.NewJobDefinition[string]("lintJob")
+	stepA, err := asyncjob.AddStepWithStaticFunc(job, "A", func(ctx context.Context) (string, error) { return "a", nil })
+	assert.NoError(t, err)
+	stepB, err := asyncjob.StepAfterWithStaticFunc(job, "B", stepA, func(ctx context.Context, a string) (string, error) { return a + "b", nil })
+	assert.NoError(t, err)
+	_, err = asyncjob.AddStepWithStaticFunc(job, "C", func(ctx context.Context) (string, error) { return "c", nil }, asyncjob.ExecuteAfter(stepA), asyncjob.ExecuteAfter(stepB))
+	assert.NoError(t, err)
+	rootStep, ok := job.GetStep(job.GetName())
+	assert.True(t, ok)
+	_, err = asyncjob.AddStepWithStaticFunc(job, "D", func(ctx context.Context) (string, error) { return "d", nil }, asyncjob.ExecuteAfter(rootStep))
+	assert.NoError(t, err)
+
+	warnings, err := job.Validate()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		`UnusedStepOutput: output of step "B" is never consumed by other steps`,
+		`RedundantDependency: step "C" executes after "A", which is already implied by depending on "B"`,
+		`UnusedStepOutput: output of step "C" is never consumed by other steps`,
+		`ExplicitRootDependency: step "D" depends on root step "lintJob" explicitly, every step runs after it already`,
+		`UnusedStepOutput: output of step "D" is never consumed by other steps`,
+	}, validationWarningStrings(warnings))
+	assert.False(t, job.Sealed())
+
+	jobWithResult, err := asyncjob.JobWithResult(job, stepB)
+	assert.NoError(t, err)
+	warnings, err = jobWithResult.Validate()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		`RedundantDependency: step "C" executes after "A", which is already implied by depending on "B"`,
+		`UnusedStepOutput: output of step "C" is never consumed by other steps`,
+		`ExplicitRootDependency: step "D" depends on root step "lintJob" explicitly, every step runs after it already`,
+		`UnusedStepOutput: output of step "D" is never consumed by other steps`,
+		`StepNotReachingResult: step "C" has no path to result step "B", its outcome doesn't affect the result`,
+		`StepNotReachingResult: step "D" has no path to result step "B", its outcome doesn't affect the result`,
+	}, validationWarningStrings(warnings))
+}
+
+func validationWarningStrings(warnings []asyncjob.ValidationWarning) []string {
+	result := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		result = append(result, warning.String())
+	}
+	return result
+}
+
+func TestCriticalPath(t *testing.T) {
+	t.Parallel()
+
+	sleepStep := func(d time.Duration) asynctask.AsyncFunc[time.Duration] {
+		return func(ctx context.Context) (time.Duration, error) {
+			time.Sleep(d)
+			return d, nil
+		}
+	}
+	job := asyncjob.NewJobDefinition[string]("criticalPathJob")
+	slow, err := asyncjob.AddStepWithStaticFunc(job, "Slow", sleepStep(60*time.Millisecond))
+	assert.NoError(t, err)
+	fast, err := asyncjob.AddStepWithStaticFunc(job, "Fast", sleepStep(5*time.Millisecond))
+	assert.NoError(t, err)
+	_, err = asyncjob.StepAfterBothWithStaticFunc(job, "Join", slow, fast, func(ctx context.Context, a, b time.Duration) (time.Duration, error) {
+		return a + b, nil
+	})
+	assert.NoError(t, err)
+
+	jobInstance := job.Start(context.Background(), "input")
+	_, err = jobInstance.CriticalPath()
+	assert.ErrorIs(t, err, asyncjob.ErrJobInstanceNotFinished)
+	assert.NoError(t, jobInstance.Wait(context.Background()))
+
+	criticalPath, err := jobInstance.CriticalPath()
+	assert.NoError(t, err)
+	names := make([]string, 0, len(criticalPath.Steps))
+	for _, step := range criticalPath.Steps {
+		names = append(names, step.Name)
+	}
+	assert.Equal(t, []string{"criticalPathJob", "Slow", "Join"}, names)
+	assert.GreaterOrEqual(t, criticalPath.Steps[1].Duration, 60*time.Millisecond)
+	assert.GreaterOrEqual(t, criticalPath.Duration, 60*time.Millisecond)
+	assert.Equal(t, time.Duration(0), criticalPath.Slack["Slow"])
+	assert.Equal(t, time.Duration(0), criticalPath.Slack["Join"])
+	assert.Greater(t, criticalPath.Slack["Fast"], 40*time.Millisecond)
+
+	buf := &strings.Builder{}
+	assert.NoError(t, jobInstance.Render(buf, criticalPath.Highlight(graph.DotRenderer{})))
+	assert.Regexp(t, `"Slow" -> "Join" \[[^\]]*penwidth="3"`, buf.String())
+	assert.NotRegexp(t, `"Fast" -> "Join" \[[^\]]*penwidth="3"`, buf.String())
+}
+
+func TestExportTrace(t *testing.T) {
+	t.Parallel()
+
+	job := asyncjob.NewJobDefinition[string]("traceJob")
+	attempts := 0
+	flaky, err := asyncjob.AddStepWithStaticFunc(job, "Flaky", func(ctx context.Context) (int, error) {
+		attempts++
+		time.Sleep(2 * time.Millisecond)
+		if attempts < 3 {
+			return 0, fmt.Errorf("attempt %d failed", attempts)
+		}
+		return attempts, nil
+	}, asyncjob.WithRetry(newLinearRetryPolicy(5*time.Millisecond, 3)))
+	assert.NoError(t, err)
+	steady, err := asyncjob.AddStepWithStaticFunc(job, "Steady", func(ctx context.Context) (int, error) {
+		time.Sleep(5 * time.Millisecond)
+		return 1, nil
+	})
+	assert.NoError(t, err)
+	_, err = asyncjob.StepAfterBothWithStaticFunc(job, "Sum", flaky, steady, func(ctx context.Context, a, b int) (int, error) {
+		return a + b, nil
+	})
+	assert.NoError(t, err)
+
+	jobInstance := job.Start(context.Background(), "input")
+	assert.NoError(t, jobInstance.Wait(context.Background()))
+
+	stepInstance, ok := jobInstance.GetStepInstance("Flaky")
+	assert.True(t, ok)
+	retried := stepInstance.ExecutionData().Retried
+	assert.Equal(t, uint(2), retried.Count)
+	assert.Equal(t, 2, len(retried.Attempts))
+	assert.EqualError(t, retried.Attempts[0].Error, "attempt 1 failed")
+	assert.Equal(t, 5*time.Millisecond, retried.Attempts[0].Backoff)
+
+	buf := &strings.Builder{}
+	assert.NoError(t, jobInstance.ExportTrace(buf))
+	trace := struct {
+		TraceEvents []struct {
+			Name string         `json:"name"`
+			Ph   string         `json:"ph"`
+			Ts   float64        `json:"ts"`
+			Dur  float64        `json:"dur"`
+			Tid  int            `json:"tid"`
+			Args map[string]any `json:"args"`
+		} `json:"traceEvents"`
+	}{}
+	assert.NoError(t, json.Unmarshal([]byte(buf.String()), &trace))
+
+	slices := map[string]int{}
+	lanes := map[string]int{}
+	ends := map[string]float64{}
+	flows := 0
+	for _, event := range trace.TraceEvents {
+		switch event.Ph {
+		case "X":
+			slices[event.Name]++
+			lanes[event.Name] = event.Tid
+			ends[event.Name] = event.Ts + event.Dur
+		}
+	}
+	for _, event := range trace.TraceEvents {
+		if event.Ph != "s" {
+			continue
+		}
+		flows++
+		// flow starts 1µs before the end of the dependency slice on its lane
+		switch event.Tid {
+		case lanes["Steady"]:
+			assert.InDelta(t, ends["Steady"]-1, event.Ts, 0.01)
+		case lanes["Flaky"]:
+			assert.InDelta(t, ends["Flaky"]-1, event.Ts, 0.01)
+		default:
+			t.Errorf("flow starts on lane %d, no dependency is on it", event.Tid)
+		}
+	}
+	// root step is not executed, so not included
+	assert.Equal(t, map[string]int{"Flaky": 1, "Steady": 1, "Sum": 1, "attempt 1": 1, "attempt 2": 1, "attempt 3": 1, "backoff": 2}, slices)
+	assert.NotEqual(t, lanes["Flaky"], lanes["Steady"])
+	assert.Equal(t, 2, flows)
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	t.Parallel()
+
+	jd, err := BuildJob(map[string]asyncjob.RetryPolicy{})
+	assert.NoError(t, err)
+	jobInstance := jd.Start(context.Background(), NewSqlJobLib(&SqlSummaryJobParameters{
+		ServerName: "server1",
+		Table1:     "table1",
+		Query1:     "query1",
+		Table2:     "table2",
+		Query2:     "query2",
+		ErrorInjection: map[string]func() error{
+			"ExecuteQuery.server1.table1.query1": func() error { return fmt.Errorf("query <exceeded> memory limit") },
+		},
+	}))
+	assert.Error(t, jobInstance.Wait(context.Background()))
+
+	buf := &strings.Builder{}
+	assert.NoError(t, jobInstance.WriteHTMLReport(buf))
+	report := buf.String()
+	assert.True(t, strings.HasPrefix(report, "<!DOCTYPE html>"))
+	// offline: no external resources
+	assert.NotContains(t, report, "<script")
+	assert.NotContains(t, report, "<link")
+	assert.NotContains(t, report, "ZgotmplZ")
+	// graph, steps table and timeline
+	assert.Contains(t, report, "<svg")
+	assert.Contains(t, report, ">QueryTable1</text>")
+	layout := asyncjob.DefaultGraphLayout()
+	assert.Contains(t, report, fmt.Sprintf(`width="%d" height="%d"`, layout.NodeWidth, layout.NodeHeight))
+	assert.Contains(t, report, `class="bar"`)
+	// root cause chain, error text is escaped and collapsible
+	assert.Contains(t, report, "<details><summary>step &#34;QueryTable1&#34; failed: query &lt;exceeded&gt; memory limit</summary>")
+	assert.Contains(t, report, "<pre>query &lt;exceeded&gt; memory limit</pre>")
+}
+
+func TestRenderTerminal(t *testing.T) {
+	t.Parallel()
+
+	gate := make(chan struct{})
+	job := asyncjob.NewJobDefinition[string]("terminalJob")
+	_, err := asyncjob.AddStepWithStaticFunc(job, "WaitForGate", func(ctx context.Context) (string, error) {
+		<-gate
+		return "opened", nil
+	})
+	assert.NoError(t, err)
+
+	jobInstance := job.Start(context.Background(), "input")
+	buf := &strings.Builder{}
+	assert.NoError(t, jobInstance.RenderTerminal(context.Background(), buf))
+	t.Log("\n" + buf.String())
+	assert.True(t, strings.HasPrefix(buf.String(), fmt.Sprintf("terminalJob (%s): running\n", jobInstance.GetJobInstanceId())))
+	assert.Regexp(t, `│ WaitForGate \[(pending|running)\] +│`, buf.String())
+	assert.Contains(t, buf.String(), "│ ← terminalJob ")
+	assert.NotContains(t, buf.String(), "\x1b[")
+
+	followed := make(chan string)
+	go func() {
+		followBuf := &strings.Builder{}
+		assert.NoError(t, jobInstance.RenderTerminal(context.Background(), followBuf, asyncjob.WithTerminalFollow(), asyncjob.WithTerminalColors()))
+		followed <- followBuf.String()
+	}()
+	close(gate)
+
+	select {
+	case output := <-followed:
+		// last frame redraws over previous one, with final state
+		frames := strings.Split(output, "\x1b[J")
+		assert.Greater(t, len(frames), 1)
+		lastFrame := frames[len(frames)-1]
+		assert.Contains(t, lastFrame, "completed\n")
+		assert.Contains(t, lastFrame, "\x1b[32m│ WaitForGate [completed] │\x1b[0m")
+	case <-time.After(time.Second):
+		assert.Fail(t, "RenderTerminal should return once job finished")
+	}
+}
+
+func TestJobWaitAll(t *testing.T) {
+	t.Parallel()
+
+	jd, err := BuildJob(map[string]asyncjob.RetryPolicy{})
+	assert.NoError(t, err)
+	jobInstance := jd.Start(context.Background(), NewSqlJobLib(&SqlSummaryJobParameters{
+		ServerName: "server1",
+		Table1:     "table1",
+		Query1:     "query1",
+		Table2:     "table2",
+		Query2:     "query2",
+		ErrorInjection: map[string]func() error{
+			"ExecuteQuery.server1.table1.query1": func() error { return fmt.Errorf("query1 exeeded memory limit") },
+			"ExecuteQuery.server1.table2.query2": func() error { return fmt.Errorf("query2 exeeded memory limit") },
+		},
+	}))
+
+	err = jobInstance.WaitAll(context.Background())
+	multiErr := &asyncjob.MultiJobError{}
+	assert.True(t, errors.As(err, &multiErr))
+	assert.Equal(t, 2, len(multiErr.Failures))
+	failedSteps := map[string][]string{}
+	for _, failure := range multiErr.Failures {
+		assert.Equal(t, asyncjob.ErrStepFailed, failure.Code)
+		failedSteps[failure.StepName] = failure.BlockedSteps
+	}
+	assert.Equal(t, map[string][]string{
+		"QueryTable1": {"Summarize", "EmailNotification"},
+		"QueryTable2": {"Summarize", "EmailNotification"},
+	}, failedSteps)
+	assert.Contains(t, err.Error(), "query1 exeeded memory limit")
+	assert.Contains(t, err.Error(), "query2 exeeded memory limit")
+
+	// compatible with errors.As on JobError
+	jobErr := &asyncjob.JobError{}
+	assert.True(t, errors.As(err, &jobErr))
+	assert.Equal(t, asyncjob.ErrStepFailed, jobErr.Code)
+
+	// Wait still returns a single root cause
+	err = jobInstance.Wait(context.Background())
+	assert.False(t, errors.As(err, &multiErr))
+
+	// no error if job succeeded
+	jobInstance = jd.Start(context.Background(), NewSqlJobLib(&SqlSummaryJobParameters{
+		ServerName: "server1",
+		Table1:     "table1",
+		Query1:     "query1",
+		Table2:     "table2",
+		Query2:     "query2",
+	}))
+	assert.NoError(t, jobInstance.WaitAll(context.Background()))
+}
+
+func TestJobBlockedSteps(t *testing.T) {
+	t.Parallel()
+
+	jd, err := BuildJob(map[string]asyncjob.RetryPolicy{})
+	assert.NoError(t, err)
+	jobInstance := jd.Start(context.Background(), NewSqlJobLib(&SqlSummaryJobParameters{
+		ServerName: "server1",
+		Table1:     "table1",
+		Query1:     "query1",
+		Table2:     "table2",
+		Query2:     "query2",
+		ErrorInjection: map[string]func() error{
+			"ExecuteQuery.server1.table1.query1": func() error { return fmt.Errorf("query exeeded memory limit") },
+		},
+	}))
+	err = jobInstance.Wait(context.Background())
+	rootErr := &asyncjob.JobError{}
+	assert.True(t, errors.As(err, &rootErr))
+	assert.Equal(t, "QueryTable1", rootErr.StepInstance.GetName())
+
+	// Summarize takes input from QueryTable1, EmailNotification executes after Summarize
+	for stepName, precedentStepName := range map[string]string{"Summarize": "QueryTable1", "EmailNotification": "Summarize"} {
+		stepInstance, ok := jobInstance.GetStepInstance(stepName)
+		assert.True(t, ok)
+		assert.Equal(t, asyncjob.StepStateBlocked, stepInstance.GetState())
+		jobErr := &asyncjob.JobError{}
+		assert.True(t, errors.As(stepInstance.GetError(), &jobErr))
+		assert.Equal(t, asyncjob.ErrPrecedentStepFailed, jobErr.Code)
+		assert.Contains(t, jobErr.Error(), fmt.Sprintf("precedent step %q failed", precedentStepName))
+		assert.Equal(t, rootErr, jobErr.RootCause())
+	}
+
+	dot, err := jobInstance.Visualize()
+	assert.NoError(t, err)
+	assert.Contains(t, dot, `"Summarize" [fillcolor="pink" label="Summarize" shape="hexagon" style="filled,dotted"`)
+	assert.Contains(t, dot, `"Summarize" -> "EmailNotification" [color="red" style="dotted"`)
+}
@@ -0,0 +1,165 @@
+package asyncjob
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+//go:embed report.html
+var reportTemplateText string
+
+var reportTemplate = template.Must(template.New("report").Parse(reportTemplateText))
+
+// reportErrorSummaryLength is the length of error text shown before expanding it.
+const reportErrorSummaryLength = 80
+
+// GraphLayout is the size of step boxes and gaps between them, when steps are drawn by level, in pixels.
+//
+//	shared by WriteHTMLReport and the httpdebug page, so both draw the graph the same way.
+type GraphLayout struct {
+	NodeWidth  int `json:"nodeWidth"`
+	NodeHeight int `json:"nodeHeight"`
+	ColumnGap  int `json:"columnGap"`
+	RowGap     int `json:"rowGap"`
+	Margin     int `json:"margin"`
+}
+
+// DefaultGraphLayout returns the layout used by WriteHTMLReport and the httpdebug page.
+func DefaultGraphLayout() GraphLayout {
+	return GraphLayout{NodeWidth: 160, NodeHeight: 36, ColumnGap: 70, RowGap: 24, Margin: 20}
+}
+
+type reportData struct {
+	Job        *JobInstanceSnapshot
+	Duration   time.Duration
+	Generated  time.Time
+	Graph      reportGraph
+	Steps      []reportStep
+	RootCauses []reportError
+}
+
+type reportGraph struct {
+	Layout        GraphLayout
+	Width, Height int
+	Nodes         []reportNode
+	Edges         []reportEdge
+}
+
+type reportNode struct {
+	Name           string
+	X, Y           int
+	LabelX, LabelY int
+	Color          string
+	Dashed         bool
+	Title          string
+}
+
+type reportEdge struct {
+	Path string
+}
+
+type reportStep struct {
+	*StepInstanceSnapshot
+	Color string
+	Error *reportError
+	// offset and width of the timeline bar, in percent of job duration.
+	Offset, Width float64
+}
+
+type reportError struct {
+	Summary string
+	Text    string
+}
+
+func newReportError(text string) *reportError {
+	summary, _, _ := strings.Cut(text, "\n")
+	if runes := []rune(summary); len(runes) > reportErrorSummaryLength {
+		summary = string(runes[:reportErrorSummaryLength]) + "..."
+	}
+	return &reportError{Summary: summary, Text: text}
+}
+
+// WriteHTMLReport writes a self-contained HTML report of the job instance, it can be viewed offline.
+//
+//	report contains the DAG, a table of steps with state, duration, retries and error,
+//	a timeline of steps, and the root cause chain if the job failed. long error text is collapsed.
+func (ji *JobInstance[T]) WriteHTMLReport(w io.Writer) error {
+	snapshot := ji.Snapshot()
+	data := &reportData{Job: snapshot, Generated: time.Now()}
+
+	jobStartTime, jobEndTime := ji.StartTime(), ji.EndTime()
+	if jobEndTime.IsZero() {
+		jobEndTime = time.Now()
+	}
+	data.Duration = jobEndTime.Sub(jobStartTime)
+
+	stepsByName := make(map[string]*StepInstanceSnapshot, len(snapshot.Steps))
+	for _, step := range snapshot.Steps {
+		stepsByName[step.Name] = step
+	}
+
+	// lay out steps by level, same as stages in JobDefinition.Explain
+	layout := DefaultGraphLayout()
+	data.Graph = reportGraph{Layout: layout}
+	positions := make(map[string][2]int)
+	for column, level := range ji.Definition.stepsDag.Levels() {
+		row := 0
+		for _, stepDef := range level {
+			step, ok := stepsByName[stepDef.GetName()]
+			if !ok {
+				continue
+			}
+			x := layout.Margin + column*(layout.NodeWidth+layout.ColumnGap)
+			y := layout.Margin + row*(layout.NodeHeight+layout.RowGap)
+			positions[step.Name] = [2]int{x, y}
+			data.Graph.Width = max(data.Graph.Width, x+layout.NodeWidth+layout.Margin)
+			data.Graph.Height = max(data.Graph.Height, y+layout.NodeHeight+layout.Margin)
+			title := fmt.Sprintf("State: %s\nDuration: %s", step.State, step.Duration)
+			if step.Error != "" {
+				title += "\nError: " + step.Error
+			}
+			data.Graph.Nodes = append(data.Graph.Nodes, reportNode{Name: step.Name, X: x, Y: y, LabelX: x + layout.NodeWidth/2, LabelY: y + layout.NodeHeight/2 + 4, Color: step.State.Color(), Dashed: step.Restored, Title: title})
+			row++
+		}
+	}
+	for _, step := range snapshot.Steps {
+		to, ok := positions[step.Name]
+		if !ok {
+			continue
+		}
+		for _, dependency := range step.DependsOn {
+			from, ok := positions[dependency]
+			if !ok {
+				continue
+			}
+			x1, y1 := from[0]+layout.NodeWidth, from[1]+layout.NodeHeight/2
+			x2, y2 := to[0], to[1]+layout.NodeHeight/2
+			mid := (x1 + x2) / 2
+			data.Graph.Edges = append(data.Graph.Edges, reportEdge{Path: fmt.Sprintf("M%d,%d C%d,%d %d,%d %d,%d", x1, y1, mid, y1, mid, y2, x2, y2)})
+		}
+	}
+
+	for _, step := range snapshot.Steps {
+		reported := reportStep{StepInstanceSnapshot: step, Color: step.State.Color()}
+		if step.Error != "" {
+			reported.Error = newReportError(step.Error)
+		}
+		if step.StartTime != nil && data.Duration > 0 {
+			reported.Offset = 100 * float64(step.StartTime.Sub(jobStartTime)) / float64(data.Duration)
+			reported.Width = max(0.2, 100*float64(step.Duration)/float64(data.Duration))
+		}
+		data.Steps = append(data.Steps, reported)
+	}
+
+	// root cause chain, from the job error down to the error returned by the step.
+	for err := ji.Err(); err != nil; err = errors.Unwrap(err) {
+		data.RootCauses = append(data.RootCauses, *newReportError(err.Error()))
+	}
+
+	return reportTemplate.Execute(w, data)
+}
@@ -1,26 +1,39 @@
 package asyncjob
 
 import (
+	"errors"
 	"time"
 )
 
 // internal retryer to execute RetryPolicy interface
 type retryer[T any] struct {
 	retryPolicy RetryPolicy
-	retryReport *RetryReport
+	onRetry     func(attempt RetryAttempt)
 	function    func() (T, error)
 }
 
-func newRetryer[T any](policy RetryPolicy, report *RetryReport, toRetry func() (T, error)) *retryer[T] {
-	return &retryer[T]{retryPolicy: policy, retryReport: report, function: toRetry}
+// newRetryer creates a retryer, onRetry is invoked before each retry, and is responsible for bookkeeping (RetryReport).
+func newRetryer[T any](policy RetryPolicy, onRetry func(attempt RetryAttempt), toRetry func() (T, error)) *retryer[T] {
+	return &retryer[T]{retryPolicy: policy, onRetry: onRetry, function: toRetry}
 }
 
+// Run invokes the function, and retries it as long as RetryPolicy allows.
+//
+//	a panic (PanicError) is not retried, the step is broken rather than hitting a transient failure.
 func (r retryer[T]) Run() (T, error) {
+	var retryCount uint
+	attemptStart := time.Now()
 	t, err := r.function()
 	for err != nil {
-		if shouldRetry, duration := r.retryPolicy.ShouldRetry(err, r.retryReport.Count); shouldRetry {
-			r.retryReport.Count++
+		panicErr := &PanicError{}
+		if errors.As(err, &panicErr) {
+			break
+		}
+		if shouldRetry, duration := r.retryPolicy.ShouldRetry(err, retryCount); shouldRetry {
+			retryCount++
+			r.onRetry(RetryAttempt{StartTime: attemptStart, Duration: time.Since(attemptStart), Error: err, Backoff: duration})
 			time.Sleep(duration)
+			attemptStart = time.Now()
 			t, err = r.function()
 		} else {
 			break
@@ -0,0 +1,202 @@
+package asyncjob
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// StateStore persists state of job instances, so a job instance can be resumed after process restart.
+type StateStore interface {
+	// Save persists data under given key of the job instance, existing data with same key get overwritten.
+	Save(ctx context.Context, jobId, key string, data []byte) error
+	// Load returns all data persisted for the job instance, keyed by key. empty map if nothing persisted.
+	Load(ctx context.Context, jobId string) (map[string][]byte, error)
+	// Delete removes all data persisted for the job instance.
+	Delete(ctx context.Context, jobId string) error
+}
+
+// Codec encodes step output before it is persisted in StateStore, and decodes it on resume.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default Codec, step output need to be JSON serializable.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// InMemoryStateStore keeps state in memory, useful for testing, or resuming within same process.
+type InMemoryStateStore struct {
+	mutex sync.RWMutex
+	jobs  map[string]map[string][]byte
+}
+
+func NewInMemoryStateStore() *InMemoryStateStore {
+	return &InMemoryStateStore{
+		jobs: make(map[string]map[string][]byte),
+	}
+}
+
+func (s *InMemoryStateStore) Save(_ context.Context, jobId, key string, data []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.jobs[jobId]; !ok {
+		s.jobs[jobId] = make(map[string][]byte)
+	}
+	s.jobs[jobId][key] = append([]byte{}, data...)
+	return nil
+}
+
+func (s *InMemoryStateStore) Load(_ context.Context, jobId string) (map[string][]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make(map[string][]byte)
+	for key, data := range s.jobs[jobId] {
+		result[key] = append([]byte{}, data...)
+	}
+	return result, nil
+}
+
+func (s *InMemoryStateStore) Delete(_ context.Context, jobId string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.jobs, jobId)
+	return nil
+}
+
+// FileStateStore keeps state in files under a directory, one sub-directory per job instance, one file per key.
+//
+//	jobId and key are base64 encoded to form the file names, so any string is allowed.
+type FileStateStore struct {
+	dir string
+}
+
+func NewFileStateStore(dir string) (*FileStateStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStateStore{dir: dir}, nil
+}
+
+// Save writes data to a temp file, fsync it, then renames it into place and fsync the directory,
+// so a crash leaves either the previous file or the new one, never a truncated file.
+func (s *FileStateStore) Save(_ context.Context, jobId, key string, data []byte) error {
+	jobDir := s.jobDir(jobId)
+	_, statErr := os.Stat(jobDir)
+	if err := os.MkdirAll(jobDir, 0o700); err != nil {
+		return err
+	}
+	if errors.Is(statErr, fs.ErrNotExist) {
+		// job directory is new, persist its entry in the parent directory.
+		if err := syncDir(s.dir); err != nil {
+			return err
+		}
+	}
+
+	tmpFile, err := os.CreateTemp(jobDir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpFile.Name(), filepath.Join(jobDir, encodeFileName(key))); err != nil {
+		return err
+	}
+	return syncDir(jobDir)
+}
+
+func (s *FileStateStore) Load(_ context.Context, jobId string) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	entries, err := os.ReadDir(s.jobDir(jobId))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return result, nil
+		}
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		key, err := decodeFileName(entry.Name())
+		if err != nil {
+			// not written by us (temp file, or something else), skip it.
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.jobDir(jobId), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		result[key] = data
+	}
+
+	return result, nil
+}
+
+func (s *FileStateStore) Delete(_ context.Context, jobId string) error {
+	return os.RemoveAll(s.jobDir(jobId))
+}
+
+// syncDir flushes directory entries, like a file renamed into the directory, to disk.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// directories can't be synced on windows, skip it.
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (s *FileStateStore) jobDir(jobId string) string {
+	return filepath.Join(s.dir, encodeFileName(jobId))
+}
+
+func encodeFileName(name string) string {
+	// prefix to avoid empty name, and names start with '.'
+	return "s_" + base64.RawURLEncoding.EncodeToString([]byte(name))
+}
+
+func decodeFileName(fileName string) (string, error) {
+	if len(fileName) < 2 || fileName[:2] != "s_" {
+		return "", fs.ErrInvalid
+	}
+	name, err := base64.RawURLEncoding.DecodeString(fileName[2:])
+	return string(name), err
+}
@@ -0,0 +1,182 @@
+package asyncjob_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Azure/go-asyncjob"
+	"github.com/Azure/go-asynctask"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateStores(t *testing.T) {
+	t.Parallel()
+
+	fileStore, err := asyncjob.NewFileStateStore(t.TempDir())
+	assert.NoError(t, err)
+
+	for name, store := range map[string]asyncjob.StateStore{
+		"InMemory": asyncjob.NewInMemoryStateStore(),
+		"File":     fileStore,
+	} {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			state, err := store.Load(ctx, "../job1")
+			assert.NoError(t, err)
+			assert.Empty(t, state)
+
+			assert.NoError(t, store.Save(ctx, "../job1", "step/1", []byte("v1")))
+			assert.NoError(t, store.Save(ctx, "../job1", "step/1", []byte("v2")))
+			assert.NoError(t, store.Save(ctx, "../job1", "", []byte("empty key")))
+			assert.NoError(t, store.Save(ctx, "job2", "step/1", []byte("other job")))
+
+			state, err = store.Load(ctx, "../job1")
+			assert.NoError(t, err)
+			assert.Equal(t, map[string][]byte{"step/1": []byte("v2"), "": []byte("empty key")}, state)
+
+			assert.NoError(t, store.Delete(ctx, "../job1"))
+			state, err = store.Load(ctx, "../job1")
+			assert.NoError(t, err)
+			assert.Empty(t, state)
+
+			state, err = store.Load(ctx, "job2")
+			assert.NoError(t, err)
+			assert.Equal(t, map[string][]byte{"step/1": []byte("other job")}, state)
+		})
+	}
+}
+
+type resumeTestResult struct {
+	Value string
+}
+
+func TestJobResume(t *testing.T) {
+	t.Parallel()
+
+	var expensiveCount, flakyCount atomic.Int32
+	shouldFail := atomic.Bool{}
+	shouldFail.Store(true)
+
+	job := asyncjob.NewJobDefinition[string]("resumeJob")
+	expensiveStep, err := asyncjob.AddStep(job, "Expensive", func(input string) asynctask.AsyncFunc[*resumeTestResult] {
+		return func(ctx context.Context) (*resumeTestResult, error) {
+			expensiveCount.Add(1)
+			return &resumeTestResult{Value: input + "-expensive"}, nil
+		}
+	})
+	assert.NoError(t, err)
+	flakyStep, err := asyncjob.StepAfterWithStaticFunc(job, "Flaky", expensiveStep, func(ctx context.Context, r *resumeTestResult) (string, error) {
+		flakyCount.Add(1)
+		if shouldFail.Load() {
+			return "", fmt.Errorf("process crashed")
+		}
+		return r.Value + "-flaky", nil
+	})
+	assert.NoError(t, err)
+	jobWithResult, err := asyncjob.JobWithResult(job, flakyStep)
+	assert.NoError(t, err)
+
+	_, err = job.Resume(context.Background(), "resumeJob1", "input")
+	assert.True(t, errors.Is(err, asyncjob.ErrStateStoreNotConfigured))
+
+	store := asyncjob.NewInMemoryStateStore()
+	jobInstance := jobWithResult.Start(context.Background(), "input", asyncjob.WithJobId("resumeJob1"), asyncjob.WithStateStore(store))
+	assert.Error(t, jobInstance.Wait(context.Background()))
+	assert.Equal(t, int32(1), expensiveCount.Load())
+	assert.Equal(t, int32(1), flakyCount.Load())
+
+	shouldFail.Store(false)
+	resumedInstance, err := jobWithResult.Resume(context.Background(), "resumeJob1", "input", asyncjob.WithStateStore(store))
+	assert.NoError(t, err)
+	result, err := resumedInstance.Result(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "input-expensive-flaky", result)
+	assert.NoError(t, resumedInstance.Wait(context.Background()))
+
+	// expensive step is restored, not executed again
+	assert.Equal(t, int32(1), expensiveCount.Load())
+	assert.Equal(t, int32(2), flakyCount.Load())
+	assert.Equal(t, "resumeJob1", resumedInstance.GetJobInstanceId())
+
+	expensiveInstance, ok := resumedInstance.GetStepInstance("Expensive")
+	assert.True(t, ok)
+	assert.True(t, expensiveInstance.(*asyncjob.StepInstance[*resumeTestResult]).Restored())
+	assert.Equal(t, asyncjob.StepStateCompleted, expensiveInstance.GetState())
+
+	for _, step := range resumedInstance.Snapshot().Steps {
+		assert.Equal(t, step.Name == "Expensive", step.Restored, step.Name)
+	}
+
+	dotGraph, err := resumedInstance.Visualize()
+	assert.NoError(t, err)
+	assert.Contains(t, dotGraph, "Restored from StateStore")
+
+	// resume with all steps completed, nothing is executed
+	fullyRestored, err := jobWithResult.Resume(context.Background(), "resumeJob1", "input", asyncjob.WithStateStore(store))
+	assert.NoError(t, err)
+	result, err = fullyRestored.Result(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "input-expensive-flaky", result)
+	assert.Equal(t, int32(1), expensiveCount.Load())
+	assert.Equal(t, int32(2), flakyCount.Load())
+}
+
+type failingStateStore struct {
+	*asyncjob.InMemoryStateStore
+}
+
+func (s *failingStateStore) Save(ctx context.Context, jobId, key string, data []byte) error {
+	return fmt.Errorf("disk full")
+}
+
+func TestJobSaveStepStateError(t *testing.T) {
+	t.Parallel()
+
+	job := asyncjob.NewJobDefinition[string]("saveStateErrorJob")
+	_, err := asyncjob.AddStepWithStaticFunc(job, "Echo", func(ctx context.Context) (string, error) {
+		return "echo", nil
+	})
+	assert.NoError(t, err)
+
+	jobInstance := job.Start(context.Background(), "input", asyncjob.WithStateStore(&failingStateStore{asyncjob.NewInMemoryStateStore()}))
+	err = jobInstance.Wait(context.Background())
+	assert.Error(t, err)
+
+	jobErr := &asyncjob.JobError{}
+	assert.True(t, errors.As(err, &jobErr))
+	assert.Equal(t, asyncjob.ErrSaveStepState, jobErr.Code)
+	assert.EqualError(t, err, "step \"Echo\" failed to save state: disk full")
+}
+
+func TestJobResumeCorruptedState(t *testing.T) {
+	t.Parallel()
+
+	var echoCount atomic.Int32
+	job := asyncjob.NewJobDefinition[string]("corruptedStateJob")
+	_, err := asyncjob.AddStepWithStaticFunc(job, "Echo", func(ctx context.Context) (string, error) {
+		echoCount.Add(1)
+		return "echo", nil
+	})
+	assert.NoError(t, err)
+
+	store := asyncjob.NewInMemoryStateStore()
+	jobInstance := job.Start(context.Background(), "input", asyncjob.WithJobId("corruptedStateJob1"), asyncjob.WithStateStore(store))
+	assert.NoError(t, jobInstance.Wait(context.Background()))
+
+	// step output truncated by a crash, not a valid JSON anymore.
+	assert.NoError(t, store.Save(context.Background(), "corruptedStateJob1", "Echo", []byte(`"ec`)))
+
+	resumedInstance, err := job.Resume(context.Background(), "corruptedStateJob1", "input", asyncjob.WithStateStore(store))
+	assert.Nil(t, resumedInstance)
+	jobErr := &asyncjob.JobError{}
+	assert.True(t, errors.As(err, &jobErr))
+	assert.Equal(t, asyncjob.ErrRestoreStepState, jobErr.Code)
+	assert.EqualError(t, err, "step \"Echo\" failed to restore state: unexpected end of JSON input")
+	// step is not executed again silently.
+	assert.Equal(t, int32(1), echoCount.Load())
+}
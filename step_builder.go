@@ -2,9 +2,8 @@ package asyncjob
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"runtime/debug"
-	"time"
 
 	"github.com/Azure/go-asynctask"
 )
@@ -25,19 +24,16 @@ func AddStep[JT, ST any](j *JobDefinition[JT], stepName string, stepFuncCreator
 	if len(precedingDefSteps) == 0 {
 		precedingDefSteps = append(precedingDefSteps, j.getRootStep())
 		stepD.executionOptions.DependOn = append(stepD.executionOptions.DependOn, j.getRootStep().GetName())
+		stepD.implicitRootDependency = true
 	}
 
-	stepD.instanceCreator = func(ctx context.Context, ji JobInstanceMeta) StepInstanceMeta {
-		// TODO: error is ignored here
-		precedingInstances, precedingTasks, _ := getDependsOnStepInstances(stepD, ji)
-
-		jiStrongTyped := ji.(*JobInstance[JT])
-		stepFunc := stepFuncCreator(jiStrongTyped.input)
+	stepD.instanceCreator = newInstanceCreator(stepD, func(ctx context.Context, ji *JobInstance[JT], stepInstance *StepInstance[ST], precedingTasks []asynctask.Waitable) (*asynctask.Task[ST], error) {
+		stepFunc := stepFuncCreator(ji.input)
 		stepFuncWithPanicHandling := func(ctx context.Context) (result ST, err error) {
 			// handle panic from user code
 			defer func() {
 				if r := recover(); r != nil {
-					err = fmt.Errorf("panic cought: %v, StackTrace: %s", r, debug.Stack())
+					err = newPanicError(r)
 				}
 			}()
 
@@ -45,11 +41,8 @@ func AddStep[JT, ST any](j *JobDefinition[JT], stepName string, stepFuncCreator
 			return result, err
 		}
 
-		stepInstance := newStepInstance(stepD, ji)
-		stepInstance.task = asynctask.Start(ctx, instrumentedAddStep(stepInstance, precedingTasks, stepFuncWithPanicHandling))
-		ji.addStepInstance(stepInstance, precedingInstances...)
-		return stepInstance
-	}
+		return asynctask.Start(ctx, instrumentedAddStep(stepInstance, precedingTasks, stepFuncWithPanicHandling)), nil
+	})
 
 	if err := j.addStep(stepD, precedingDefSteps...); err != nil {
 		return nil, err
@@ -64,22 +57,19 @@ func StepAfter[JT, PT, ST any](j *JobDefinition[JT], stepName string, parentStep
 	}
 
 	stepD := newStepDefinition[ST](stepName, stepTypeTask, append(optionDecorators, ExecuteAfter(parentStep))...)
-	precedingDefSteps, err := getDependsOnSteps(j, stepD.DependsOn())
+	stepD.inputSteps = []string{parentStep.GetName()}
+	precedingDefSteps, err := getDependsOnSteps(j, stepD.DependsOn(), parentStep)
 	if err != nil {
 		return nil, err
 	}
 
-	stepD.instanceCreator = func(ctx context.Context, ji JobInstanceMeta) StepInstanceMeta {
-		// TODO: error is ignored here
-		precedingInstances, precedingTasks, _ := getDependsOnStepInstances(stepD, ji)
-
-		jiStrongTyped := ji.(*JobInstance[JT])
-		stepFunc := stepAfterFuncCreator(jiStrongTyped.input)
+	stepD.instanceCreator = newInstanceCreator(stepD, func(ctx context.Context, ji *JobInstance[JT], stepInstance *StepInstance[ST], precedingTasks []asynctask.Waitable) (*asynctask.Task[ST], error) {
+		stepFunc := stepAfterFuncCreator(ji.input)
 		stepFuncWithPanicHandling := func(ctx context.Context, pt PT) (result ST, err error) {
 			// handle panic from user code
 			defer func() {
 				if r := recover(); r != nil {
-					err = fmt.Errorf("panic cought: %v, StackTrace: %s", r, debug.Stack())
+					err = newPanicError(r)
 				}
 			}()
 
@@ -87,13 +77,13 @@ func StepAfter[JT, PT, ST any](j *JobDefinition[JT], stepName string, parentStep
 			return result, err
 		}
 
-		parentStepInstance := getStrongTypedStepInstance(parentStep, ji)
-		stepInstance := newStepInstance(stepD, ji)
-		// here ContinueWith may not invoke instrumentedStepAfterBoth at all, if parentStep1 or parentStep2 returns error.
-		stepInstance.task = asynctask.ContinueWith(ctx, parentStepInstance.task, instrumentedStepAfter(stepInstance, precedingTasks, stepFuncWithPanicHandling))
-		ji.addStepInstance(stepInstance, precedingInstances...)
-		return stepInstance
-	}
+		parentStepInstance, err := getStrongTypedStepInstance(parentStep, ji)
+		if err != nil {
+			return nil, err
+		}
+		// not using asynctask.ContinueWith, it won't invoke instrumentedStepAfter at all if parentStep failed, then step can't be marked blocked.
+		return asynctask.Start(ctx, instrumentedStepAfter(stepInstance, precedingTasks, parentStepInstance.task, stepFuncWithPanicHandling)), nil
+	})
 
 	if err := j.addStep(stepD, precedingDefSteps...); err != nil {
 		return nil, err
@@ -113,36 +103,37 @@ func StepAfterBoth[JT, PT1, PT2, ST any](j *JobDefinition[JT], stepName string,
 	}
 
 	stepD := newStepDefinition[ST](stepName, stepTypeTask, append(optionDecorators, ExecuteAfter(parentStep1), ExecuteAfter(parentStep2))...)
-	precedingDefSteps, err := getDependsOnSteps(j, stepD.DependsOn())
+	stepD.inputSteps = []string{parentStep1.GetName(), parentStep2.GetName()}
+	precedingDefSteps, err := getDependsOnSteps(j, stepD.DependsOn(), parentStep1, parentStep2)
 	if err != nil {
 		return nil, err
 	}
 
-	stepD.instanceCreator = func(ctx context.Context, ji JobInstanceMeta) StepInstanceMeta {
-		// TODO: error is ignored here
-		precedingInstances, precedingTasks, _ := getDependsOnStepInstances(stepD, ji)
-
-		jiStrongTyped := ji.(*JobInstance[JT])
-		stepFunc := stepAfterBothFuncCreator(jiStrongTyped.input)
+	stepD.instanceCreator = newInstanceCreator(stepD, func(ctx context.Context, ji *JobInstance[JT], stepInstance *StepInstance[ST], precedingTasks []asynctask.Waitable) (*asynctask.Task[ST], error) {
+		stepFunc := stepAfterBothFuncCreator(ji.input)
 		stepFuncWithPanicHandling := func(ctx context.Context, pt1 PT1, pt2 PT2) (result ST, err error) {
 			// handle panic from user code
 			defer func() {
 				if r := recover(); r != nil {
-					err = fmt.Errorf("panic cought: %v, StackTrace: %s", r, debug.Stack())
+					err = newPanicError(r)
 				}
 			}()
 
 			result, err = stepFunc(ctx, pt1, pt2)
 			return result, err
 		}
-		parentStepInstance1 := getStrongTypedStepInstance(parentStep1, ji)
-		parentStepInstance2 := getStrongTypedStepInstance(parentStep2, ji)
-		stepInstance := newStepInstance(stepD, ji)
-		// here AfterBoth may not invoke instrumentedStepAfterBoth at all, if parentStep1 or parentStep2 returns error.
-		stepInstance.task = asynctask.AfterBoth(ctx, parentStepInstance1.task, parentStepInstance2.task, instrumentedStepAfterBoth(stepInstance, precedingTasks, stepFuncWithPanicHandling))
-		ji.addStepInstance(stepInstance, precedingInstances...)
-		return stepInstance
-	}
+
+		parentStepInstance1, err := getStrongTypedStepInstance(parentStep1, ji)
+		if err != nil {
+			return nil, err
+		}
+		parentStepInstance2, err := getStrongTypedStepInstance(parentStep2, ji)
+		if err != nil {
+			return nil, err
+		}
+		// not using asynctask.AfterBoth, it won't invoke instrumentedStepAfterBoth at all if parentStep1 or parentStep2 failed, then step can't be marked blocked.
+		return asynctask.Start(ctx, instrumentedStepAfterBoth(stepInstance, precedingTasks, parentStepInstance1.task, parentStepInstance2.task, stepFuncWithPanicHandling)), nil
+	})
 
 	if err := j.addStep(stepD, precedingDefSteps...); err != nil {
 		return nil, err
@@ -167,105 +158,121 @@ func StepAfterBothWithStaticFunc[JT, PT1, PT2, ST any](j *JobDefinition[JT], ste
 
 func instrumentedAddStep[T any](stepInstance *StepInstance[T], precedingTasks []asynctask.Waitable, stepFunc func(ctx context.Context) (T, error)) func(ctx context.Context) (T, error) {
 	return func(ctx context.Context) (T, error) {
-		if err := asynctask.WaitAll(ctx, &asynctask.WaitAllOptions{}, precedingTasks...); err != nil {
-			/* this only work on ExecuteAfter (have precedent step, but not taking input from it)
-			   asynctask.ContinueWith and asynctask.AfterBoth won't invoke instrumentedFunc if any of the preceding task failed.
-			   we need to be consistent on before we do any state change or error handling. */
+		if err := waitPrecedingTasks(ctx, stepInstance, precedingTasks); err != nil {
 			return *new(T), err
 		}
 
-		stepInstance.executionData.StartTime = time.Now()
-		stepInstance.state = StepStateRunning
-		ctx = stepInstance.EnrichContext(ctx)
+		return runStep(ctx, stepInstance, stepFunc)
+	}
+}
 
-		var result T
-		var err error
-		if stepInstance.Definition.executionOptions.RetryPolicy != nil {
-			stepInstance.executionData.Retried = &RetryReport{}
-			result, err = newRetryer(stepInstance.Definition.executionOptions.RetryPolicy, stepInstance.executionData.Retried, func() (T, error) { return stepFunc(ctx) }).Run()
-		} else {
-			result, err = stepFunc(ctx)
+func instrumentedStepAfter[T, S any](stepInstance *StepInstance[S], precedingTasks []asynctask.Waitable, parentTask *asynctask.Task[T], stepFunc func(ctx context.Context, t T) (S, error)) func(ctx context.Context) (S, error) {
+	return func(ctx context.Context) (S, error) {
+		// parentTask is one of precedingTasks
+		if err := waitPrecedingTasks(ctx, stepInstance, precedingTasks); err != nil {
+			return *new(S), err
 		}
+		t, _ := parentTask.Result(context.Background())
 
-		stepInstance.executionData.Duration = time.Since(stepInstance.executionData.StartTime)
-
-		if err != nil {
-			stepInstance.state = StepStateFailed
-			return *new(T), newStepError(ErrStepFailed, stepInstance, err)
-		} else {
-			stepInstance.state = StepStateCompleted
-			return result, nil
-		}
+		return runStep(ctx, stepInstance, func(ctx context.Context) (S, error) { return stepFunc(ctx, t) })
 	}
 }
 
-func instrumentedStepAfter[T, S any](stepInstance *StepInstance[S], precedingTasks []asynctask.Waitable, stepFunc func(ctx context.Context, t T) (S, error)) func(ctx context.Context, t T) (S, error) {
-	return func(ctx context.Context, t T) (S, error) {
-		if err := asynctask.WaitAll(ctx, &asynctask.WaitAllOptions{}, precedingTasks...); err != nil {
-			/* this only work on ExecuteAfter (have precedent step, but not taking input from it)
-			   asynctask.ContinueWith and asynctask.AfterBoth won't invoke instrumentedFunc if any of the preceding task failed.
-			   we need to be consistent on before we do any state change or error handling. */
-			return *new(S), err
+func instrumentedStepAfterBoth[T, S, R any](stepInstance *StepInstance[R], precedingTasks []asynctask.Waitable, parentTask1 *asynctask.Task[T], parentTask2 *asynctask.Task[S], stepFunc func(ctx context.Context, t T, s S) (R, error)) func(ctx context.Context) (R, error) {
+	return func(ctx context.Context) (R, error) {
+		// parentTask1 and parentTask2 are in precedingTasks
+		if err := waitPrecedingTasks(ctx, stepInstance, precedingTasks); err != nil {
+			return *new(R), err
 		}
+		t, _ := parentTask1.Result(context.Background())
+		s, _ := parentTask2.Result(context.Background())
 
-		stepInstance.executionData.StartTime = time.Now()
-		stepInstance.state = StepStateRunning
-		ctx = stepInstance.EnrichContext(ctx)
+		return runStep(ctx, stepInstance, func(ctx context.Context) (R, error) { return stepFunc(ctx, t, s) })
+	}
+}
 
-		var result S
-		var err error
-		if stepInstance.Definition.executionOptions.RetryPolicy != nil {
-			stepInstance.executionData.Retried = &RetryReport{}
-			result, err = newRetryer(stepInstance.Definition.executionOptions.RetryPolicy, stepInstance.executionData.Retried, func() (S, error) { return stepFunc(ctx, t) }).Run()
-		} else {
-			result, err = stepFunc(ctx, t)
+// newInstanceCreator creates the instanceCreator of a step, the step is restored from StateStore if persisted in previous run,
+// otherwise start is called to start the task of the step.
+func newInstanceCreator[JT, T any](stepD *StepDefinition[T], start func(ctx context.Context, ji *JobInstance[JT], stepInstance *StepInstance[T], precedingTasks []asynctask.Waitable) (*asynctask.Task[T], error)) func(context.Context, JobInstanceMeta) (StepInstanceMeta, error) {
+	return func(ctx context.Context, ji JobInstanceMeta) (StepInstanceMeta, error) {
+		precedingInstances, precedingTasks, err := getDependsOnStepInstances(stepD, ji)
+		if err != nil {
+			return nil, err
 		}
 
-		stepInstance.executionData.Duration = time.Since(stepInstance.executionData.StartTime)
-
+		stepInstance := newStepInstance(stepD, ji)
+		restored, err := stepInstance.restore()
 		if err != nil {
-			stepInstance.state = StepStateFailed
-			return *new(S), newStepError(ErrStepFailed, stepInstance, err)
-		} else {
-			stepInstance.state = StepStateCompleted
-			return result, nil
+			return nil, err
+		}
+		if !restored {
+			jiStrongTyped, ok := ji.(*JobInstance[JT])
+			if !ok {
+				return nil, ErrRuntimeStepTypeMismatch.WithMessage(fmt.Sprintf(MsgRuntimeJobTypeMismatch, stepD.GetName(), ji, jiStrongTyped))
+			}
+			if stepInstance.task, err = start(ctx, jiStrongTyped, stepInstance, precedingTasks); err != nil {
+				return nil, err
+			}
 		}
+
+		ji.addStepInstance(stepInstance, precedingInstances...)
+		return stepInstance, nil
 	}
 }
 
-func instrumentedStepAfterBoth[T, S, R any](stepInstance *StepInstance[R], precedingTasks []asynctask.Waitable, stepFunc func(ctx context.Context, t T, s S) (R, error)) func(ctx context.Context, t T, s S) (R, error) {
-	return func(ctx context.Context, t T, s S) (R, error) {
+// runStep runs the step function with ContextPolicy and RetryPolicy applied, then saves the output into StateStore,
+// the step is marked finished with the error if any.
+func runStep[T any](ctx context.Context, stepInstance *StepInstance[T], stepFunc func(ctx context.Context) (T, error)) (T, error) {
+	stepInstance.markRunning()
+	ctx, err := stepInstance.enrichContext(ctx)
+	if err != nil {
+		stepErr := stepInstance.stepError(err)
+		stepInstance.markFinished(stepErr)
+		return *new(T), stepErr
+	}
+
+	var result T
+	if stepInstance.Definition.executionOptions.RetryPolicy != nil {
+		result, err = newRetryer(stepInstance.Definition.executionOptions.RetryPolicy, stepInstance.recordRetry, func() (T, error) { return stepFunc(ctx) }).Run()
+	} else {
+		result, err = stepFunc(ctx)
+	}
 
-		if err := asynctask.WaitAll(ctx, &asynctask.WaitAllOptions{}, precedingTasks...); err != nil {
-			/* this only work on ExecuteAfter (have precedent step, but not taking input from it)
-			   asynctask.ContinueWith and asynctask.AfterBoth won't invoke instrumentedFunc if any of the preceding task failed.
-			   we need to be consistent on before we do any state change or error handling. */
-			return *new(R), err
-		}
+	if err != nil {
+		stepErr := stepInstance.stepError(err)
+		stepInstance.markFinished(stepErr)
+		return *new(T), stepErr
+	}
 
-		stepInstance.executionData.StartTime = time.Now()
-		stepInstance.state = StepStateRunning
-		ctx = stepInstance.EnrichContext(ctx)
+	if err := stepInstance.JobInstance.saveStepState(ctx, stepInstance.GetName(), result); err != nil {
+		stepErr := newStepError(ErrSaveStepState, stepInstance, err)
+		stepInstance.markFinished(stepErr)
+		return *new(T), stepErr
+	}
 
-		var result R
-		var err error
-		if stepInstance.Definition.executionOptions.RetryPolicy != nil {
-			stepInstance.executionData.Retried = &RetryReport{}
-			result, err = newRetryer(stepInstance.Definition.executionOptions.RetryPolicy, stepInstance.executionData.Retried, func() (R, error) { return stepFunc(ctx, t, s) }).Run()
-		} else {
-			result, err = stepFunc(ctx, t, s)
-		}
+	stepInstance.markFinished(nil)
+	return result, nil
+}
 
-		stepInstance.executionData.Duration = time.Since(stepInstance.executionData.StartTime)
+// waitPrecedingTasks waits for all preceding tasks, and marks the step blocked if any of them failed.
+func waitPrecedingTasks[T any](ctx context.Context, stepInstance *StepInstance[T], precedingTasks []asynctask.Waitable) error {
+	err := asynctask.WaitAll(ctx, &asynctask.WaitAllOptions{}, precedingTasks...)
+	if err == nil {
+		return nil
+	}
 
-		if err != nil {
-			stepInstance.state = StepStateFailed
-			return *new(R), newStepError(ErrStepFailed, stepInstance, err)
-		} else {
-			stepInstance.state = StepStateCompleted
-			return result, nil
+	precedentErr := &JobError{}
+	if !errors.As(err, &precedentErr) {
+		// ctx is done before preceding tasks finished, they share same ctx, wait for them to tell whether any of them failed.
+		precedingErr := asynctask.WaitAll(context.Background(), &asynctask.WaitAllOptions{}, precedingTasks...)
+		if precedingErr == nil {
+			// canceled before start, not blocked by precedent steps.
+			return err
 		}
+		err = precedingErr
 	}
+
+	return stepInstance.markBlocked(err)
 }
 
 func addStepPreCheck(j JobDefinitionMeta, stepName string) error {
@@ -277,10 +284,24 @@ func addStepPreCheck(j JobDefinitionMeta, stepName string) error {
 		return ErrAddExistingStep.WithMessage(fmt.Sprintf(MsgAddExistingStep, stepName))
 	}
 
+	// step output shares key space with fingerprint in StateStore.
+	if stepName == fingerprintStateKey {
+		return ErrReservedStepName.WithMessage(fmt.Sprintf(MsgReservedStepName, stepName))
+	}
+
 	return nil
 }
 
-func getDependsOnSteps(j JobDefinitionMeta, dependsOnSteps []string) ([]StepDefinitionMeta, error) {
+// getDependsOnSteps looks up the steps depended on by name,
+//
+//	input steps are checked by identity as well, a step from another job definition with same name is refused.
+func getDependsOnSteps(j JobDefinitionMeta, dependsOnSteps []string, inputSteps ...StepDefinitionMeta) ([]StepDefinitionMeta, error) {
+	for _, inputStep := range inputSteps {
+		if registered, ok := j.GetStep(inputStep.GetName()); !ok || registered != inputStep {
+			return nil, ErrRefStepNotInJob.WithMessage(fmt.Sprintf(MsgRefStepNotInJob, inputStep.GetName()))
+		}
+	}
+
 	var precedingDefSteps []StepDefinitionMeta
 	for _, depStepName := range dependsOnSteps {
 		if depStep, ok := j.GetStep(depStepName); ok {
@@ -296,7 +317,8 @@ func getDependsOnSteps(j JobDefinitionMeta, dependsOnSteps []string) ([]StepDefi
 func getDependsOnStepInstances(stepD StepDefinitionMeta, ji JobInstanceMeta) ([]StepInstanceMeta, []asynctask.Waitable, error) {
 	var precedingInstances []StepInstanceMeta
 	var precedingTasks []asynctask.Waitable
-	for _, depStepName := range stepD.DependsOn() {
+	// same dependency listed more than once is linked once, same as the definition graph.
+	for _, depStepName := range uniqueStrings(stepD.DependsOn()) {
 		if depStep, ok := ji.GetStepInstance(depStepName); ok {
 			precedingInstances = append(precedingInstances, depStep)
 			precedingTasks = append(precedingTasks, depStep.Waitable())
@@ -314,17 +336,17 @@ func getDependsOnStepInstances(stepD StepDefinitionMeta, ji JobInstanceMeta) ([]
 //	we can create stronglyTyped stepInstance from stronglyTyped stepDefinition
 //	We cannot store strongTyped stepInstance and passing it to next step
 //	now we need this typeAssertion, to beable to link steps
-//	in theory, we have all the info, we construct the instance, if it panics, we should fix it.
-func getStrongTypedStepInstance[T any](stepD *StepDefinition[T], ji JobInstanceMeta) *StepInstance[T] {
+//	in theory, we have all the info, we construct the instance, if it returns error, we should fix it.
+func getStrongTypedStepInstance[T any](stepD *StepDefinition[T], ji JobInstanceMeta) (*StepInstance[T], error) {
 	stepInstanceMeta, ok := ji.GetStepInstance(stepD.GetName())
 	if !ok {
-		panic(fmt.Sprintf("step [%s] not found in jobInstance", stepD.GetName()))
+		return nil, ErrRuntimeStepNotFound.WithMessage(fmt.Sprintf(MsgRuntimeStepNotFound, stepD.GetName()))
 	}
 
 	stepInstance, ok := stepInstanceMeta.(*StepInstance[T])
 	if !ok {
-		panic(fmt.Sprintf("step [%s] in jobInstance is not expected Type", stepD.GetName()))
+		return nil, ErrRuntimeStepTypeMismatch.WithMessage(fmt.Sprintf(MsgRuntimeStepTypeMismatch, stepD.GetName(), stepInstanceMeta, stepInstance))
 	}
 
-	return stepInstance
+	return stepInstance, nil
 }
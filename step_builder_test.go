@@ -1,6 +1,7 @@
 package asyncjob_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/Azure/go-asyncjob"
@@ -58,6 +59,18 @@ func TestDefinitionBuilder(t *testing.T) {
 	_, err = asyncjob.StepAfterBoth(job, "Summarize2", query1Task, query3Task, summarizeQueryResultStepFunc, asyncjob.WithContextEnrichment(EnrichContext))
 	assert.EqualError(t, err, "RefStepNotInJob: trying to reference to step \"\", but it is not registered in job")
 
+	// same dependency listed twice is connected once, and reported by Validate.
+	_, err = asyncjob.StepAfter(job, "QueryTable1Twice", table1ClientTsk, queryTable1StepFunc, asyncjob.ExecuteAfter(table1ClientTsk))
+	assert.NoError(t, err)
+	_, ok := job.GetStep("QueryTable1Twice")
+	assert.True(t, ok)
+	dotGraph, err := job.Visualize()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(dotGraph, `"GetTableClient1" -> "QueryTable1Twice"`))
+	warnings, err := job.Validate()
+	assert.NoError(t, err)
+	assert.Contains(t, validationWarningStrings(warnings), `DuplicateDependency: step "QueryTable1Twice" depends on "GetTableClient1" more than once`)
+
 	assert.False(t, job.Sealed())
 	job.Seal()
 	assert.True(t, job.Sealed())
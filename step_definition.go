@@ -2,6 +2,7 @@ package asyncjob
 
 import (
 	"context"
+	"reflect"
 
 	"github.com/Azure/go-asyncjob/graph"
 )
@@ -24,7 +25,17 @@ type StepDefinitionMeta interface {
 	DotSpec() *graph.DotNodeSpec
 
 	// Instantiate a new step instance
-	createStepInstance(context.Context, JobInstanceMeta) StepInstanceMeta
+	createStepInstance(context.Context, JobInstanceMeta) (StepInstanceMeta, error)
+
+	// describe the step in DefinitionFingerprint
+	fingerprint() *StepFingerprint
+
+	getExecutionOptions() *StepExecutionOptions
+
+	// steps whose output is taken as input of this step, used by Validate
+	getInputSteps() []string
+	// root step is added to DependsOn by AddStep, not by caller, used by Validate
+	dependsOnRootImplicitly() bool
 }
 
 // StepDefinition defines a step and it's dependencies in a job definition.
@@ -32,7 +43,11 @@ type StepDefinition[T any] struct {
 	name             string
 	stepType         stepType
 	executionOptions *StepExecutionOptions
-	instanceCreator  func(context.Context, JobInstanceMeta) StepInstanceMeta
+	instanceCreator  func(context.Context, JobInstanceMeta) (StepInstanceMeta, error)
+	// inputSteps are parent steps of StepAfter and StepAfterBoth, their output is consumed by this step.
+	inputSteps []string
+	// implicitRootDependency is set when AddStep links the step to root step, as it has no preceding step.
+	implicitRootDependency bool
 }
 
 func newStepDefinition[T any](stepName string, stepType stepType, optionDecorators ...ExecutionOptionPreparer) *StepDefinition[T] {
@@ -49,6 +64,18 @@ func newStepDefinition[T any](stepName string, stepType stepType, optionDecorato
 	return step
 }
 
+func (sd *StepDefinition[T]) getExecutionOptions() *StepExecutionOptions {
+	return sd.executionOptions
+}
+
+func (sd *StepDefinition[T]) getInputSteps() []string {
+	return sd.inputSteps
+}
+
+func (sd *StepDefinition[T]) dependsOnRootImplicitly() bool {
+	return sd.implicitRootDependency
+}
+
 func (sd *StepDefinition[T]) GetName() string {
 	return sd.name
 }
@@ -57,10 +84,14 @@ func (sd *StepDefinition[T]) DependsOn() []string {
 	return sd.executionOptions.DependOn
 }
 
-func (sd *StepDefinition[T]) createStepInstance(ctx context.Context, jobInstance JobInstanceMeta) StepInstanceMeta {
+func (sd *StepDefinition[T]) createStepInstance(ctx context.Context, jobInstance JobInstanceMeta) (StepInstanceMeta, error) {
 	return sd.instanceCreator(ctx, jobInstance)
 }
 
+func (sd *StepDefinition[T]) fingerprint() *StepFingerprint {
+	return newStepFingerprint(sd.name, sd.stepType, reflect.TypeOf((*T)(nil)).Elem(), sd.DependsOn())
+}
+
 func (sd *StepDefinition[T]) DotSpec() *graph.DotNodeSpec {
 	return &graph.DotNodeSpec{
 		Name:        sd.GetName(),
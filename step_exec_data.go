@@ -11,7 +11,29 @@ type StepExecutionData struct {
 	Retried   *RetryReport
 }
 
-// RetryReport would record the retry count (could extend to include each retry duration, ...)
+// RetryReport would record the retry count, and each attempt retried.
 type RetryReport struct {
 	Count uint
+	// Attempts failed and retried, the last attempt is not included, it ends with the step.
+	Attempts []RetryAttempt
+}
+
+// RetryAttempt is a failed attempt of a step, followed by a backoff sleep before next attempt.
+type RetryAttempt struct {
+	StartTime time.Time
+	Duration  time.Duration
+	Error     error
+	// Backoff is the sleep before next attempt, given by RetryPolicy.
+	Backoff time.Duration
+}
+
+// snapshot returns a copy of the execution data, safe to hand over to other routines.
+func (sed *StepExecutionData) snapshot() StepExecutionData {
+	result := *sed
+	if sed.Retried != nil {
+		retried := *sed.Retried
+		retried.Attempts = append([]RetryAttempt(nil), sed.Retried.Attempts...)
+		result.Retried = &retried
+	}
+	return result
 }
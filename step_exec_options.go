@@ -9,6 +9,7 @@ type StepExecutionOptions struct {
 	ErrorPolicy   StepErrorPolicy
 	RetryPolicy   RetryPolicy
 	ContextPolicy StepContextPolicy
+	PanicPolicy   PanicPolicy
 
 	// dependencies that are not input.
 	DependOn []string
@@ -27,6 +28,18 @@ type RetryPolicy interface {
 //	With StepInstanceMeta you can access StepInstance, StepDefinition, JobInstance, JobDefinition.
 type StepContextPolicy func(context.Context, StepInstanceMeta) context.Context
 
+// PanicPolicy decides what happens when step function or ContextPolicy panics.
+type PanicPolicy string
+
+const (
+	// PanicPolicyRecover fails the step with ErrStepPanicked and PanicError, this is the default.
+	PanicPolicyRecover PanicPolicy = "Recover"
+	// PanicPolicyRepanic fails the step like PanicPolicyRecover, then panics again with the PanicError in goroutine calling JobInstance.Wait, useful in tests.
+	PanicPolicyRepanic PanicPolicy = "Repanic"
+	// PanicPolicyCrash panics again with the PanicError in a new goroutine, which crashes the process.
+	PanicPolicyCrash PanicPolicy = "Crash"
+)
+
 type ExecutionOptionPreparer func(*StepExecutionOptions) *StepExecutionOptions
 
 // Add precedence to a step.
@@ -53,3 +66,11 @@ func WithContextEnrichment(contextPolicy StepContextPolicy) ExecutionOptionPrepa
 		return options
 	}
 }
+
+// WithPanicPolicy decides what happens when step function or ContextPolicy panics, PanicPolicyRecover by default.
+func WithPanicPolicy(panicPolicy PanicPolicy) ExecutionOptionPreparer {
+	return func(options *StepExecutionOptions) *StepExecutionOptions {
+		options.PanicPolicy = panicPolicy
+		return options
+	}
+}
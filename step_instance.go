@@ -2,7 +2,9 @@ package asyncjob
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/Azure/go-asyncjob/graph"
@@ -16,6 +18,25 @@ const StepStateRunning StepState = "running"
 const StepStateFailed StepState = "failed"
 const StepStateCompleted StepState = "completed"
 
+// StepStateBlocked is terminal state of a step never executed, because a precedent step failed.
+const StepStateBlocked StepState = "blocked"
+
+// Color of the step state in visualization, same color scheme is used by DotSpec and the httpdebug page.
+func (s StepState) Color() string {
+	switch s {
+	case StepStateRunning:
+		return "yellow"
+	case StepStateCompleted:
+		return "green"
+	case StepStateFailed:
+		return "red"
+	case StepStateBlocked:
+		return "pink"
+	default:
+		return "gray"
+	}
+}
+
 // StepInstanceMeta is the interface for a step instance
 type StepInstanceMeta interface {
 	GetName() string
@@ -23,11 +44,41 @@ type StepInstanceMeta interface {
 	GetState() StepState
 	GetJobInstance() JobInstanceMeta
 	GetStepDefinition() StepDefinitionMeta
+	GetError() error
 	Waitable() asynctask.Waitable
 
 	DotSpec() *graph.DotNodeSpec
 }
 
+// stepInstanceInternal is implemented by StepInstance, kept out of StepInstanceMeta so that interface can be implemented outside of this package.
+type stepInstanceInternal interface {
+	snapshot() *StepInstanceSnapshot
+	executionDataSnapshot() (StepExecutionData, StepState)
+}
+
+// snapshotStep returns snapshot of the step, built from StepInstanceMeta methods if the step is not a StepInstance.
+func snapshotStep(step StepInstanceMeta) *StepInstanceSnapshot {
+	if internal, ok := step.(stepInstanceInternal); ok {
+		return internal.snapshot()
+	}
+
+	data, state := stepExecutionData(step)
+	return newStepInstanceSnapshot(step.GetName(), step.GetStepDefinition().DependsOn(), data, state, step.GetError(), false)
+}
+
+// stepExecutionData returns a copy of execution data along with the state of the step.
+func stepExecutionData(step StepInstanceMeta) (StepExecutionData, StepState) {
+	if internal, ok := step.(stepInstanceInternal); ok {
+		return internal.executionDataSnapshot()
+	}
+
+	state := step.GetState()
+	if data := step.ExecutionData(); data != nil {
+		return data.snapshot(), state
+	}
+	return StepExecutionData{}, state
+}
+
 // StepInstance is the instance of a step, within a job instance.
 type StepInstance[T any] struct {
 	Definition  *StepDefinition[T]
@@ -36,6 +87,9 @@ type StepInstance[T any] struct {
 	task          *asynctask.Task[T]
 	state         StepState
 	executionData *StepExecutionData
+	err           error
+	restored      bool
+	mutex         sync.RWMutex
 }
 
 func newStepInstance[T any](stepDefinition *StepDefinition[T], jobInstance JobInstanceMeta) *StepInstance[T] {
@@ -64,26 +118,176 @@ func (si *StepInstance[T]) GetName() string {
 }
 
 func (si *StepInstance[T]) GetState() StepState {
+	si.mutex.RLock()
+	defer si.mutex.RUnlock()
 	return si.state
 }
 
-func (si *StepInstance[T]) EnrichContext(ctx context.Context) (result context.Context) {
+// GetError returns the error of a failed step, nil otherwise.
+func (si *StepInstance[T]) GetError() error {
+	si.mutex.RLock()
+	defer si.mutex.RUnlock()
+	return si.err
+}
+
+// EnrichContext applies the ContextPolicy of the step, ctx is returned as is if ContextPolicy panics.
+func (si *StepInstance[T]) EnrichContext(ctx context.Context) context.Context {
+	result, _ := si.enrichContext(ctx)
+	return result
+}
+
+// enrichContext applies the ContextPolicy of the step, panic in ContextPolicy is returned as PanicError.
+func (si *StepInstance[T]) enrichContext(ctx context.Context) (result context.Context, err error) {
 	result = ctx
 	if si.Definition.executionOptions.ContextPolicy != nil {
-		// TODO: bubble up the error somehow
 		defer func() {
 			if r := recover(); r != nil {
-				fmt.Println("Recovered in EnrichContext", r)
+				result = ctx
+				err = newPanicError(r)
 			}
 		}()
 		result = si.Definition.executionOptions.ContextPolicy(ctx, si)
 	}
 
-	return result
+	return result, nil
+}
+
+// stepError creates error of the failed step, panic is reported with ErrStepPanicked, and handled by PanicPolicy.
+func (si *StepInstance[T]) stepError(err error) *JobError {
+	panicErr := &PanicError{}
+	if !errors.As(err, &panicErr) {
+		return newStepError(ErrStepFailed, si, err)
+	}
+
+	if si.Definition.executionOptions.PanicPolicy == PanicPolicyCrash {
+		// asynctask recovers panics in the goroutine running the step, panic in a new goroutine to crash the process.
+		go func() {
+			panic(panicErr)
+		}()
+	}
+	return newStepError(ErrStepPanicked, si, err)
 }
 
+// ExecutionData returns a copy of the step execution data, safe to read while the step is running.
 func (si *StepInstance[T]) ExecutionData() *StepExecutionData {
-	return si.executionData
+	si.mutex.RLock()
+	defer si.mutex.RUnlock()
+	data := si.executionData.snapshot()
+	return &data
+}
+
+// restore completes the step with output persisted in previous run, returns false if there is nothing to restore.
+//
+//	persisted output failed to decode is returned as ErrRestoreStepState, instead of running the step again.
+func (si *StepInstance[T]) restore() (bool, error) {
+	var result T
+	ok, err := si.JobInstance.loadStepState(si.GetName(), &result)
+	if err != nil {
+		return false, newStepError(ErrRestoreStepState, si, err)
+	}
+	if !ok {
+		return false, nil
+	}
+
+	si.task = asynctask.NewCompletedTask(result)
+	si.state = StepStateCompleted
+	si.restored = true
+	return true, nil
+}
+
+// Restored tells whether the step output is restored from StateStore instead of executed.
+func (si *StepInstance[T]) Restored() bool {
+	return si.restored
+}
+
+// markRunning moves the step to running state, and emits EventStepStarted.
+func (si *StepInstance[T]) markRunning() {
+	si.mutex.Lock()
+	si.executionData.StartTime = time.Now()
+	if si.Definition.executionOptions.RetryPolicy != nil {
+		si.executionData.Retried = &RetryReport{}
+	}
+	si.state = StepStateRunning
+	event := si.newEvent(EventStepStarted, nil)
+	si.mutex.Unlock()
+
+	si.JobInstance.emitEvent(event)
+}
+
+// recordRetry updates the RetryReport, and emits EventStepRetried.
+func (si *StepInstance[T]) recordRetry(attempt RetryAttempt) {
+	si.mutex.Lock()
+	si.executionData.Retried.Count++
+	si.executionData.Retried.Attempts = append(si.executionData.Retried.Attempts, attempt)
+	event := si.newEvent(EventStepRetried, attempt.Error)
+	si.mutex.Unlock()
+
+	si.JobInstance.emitEvent(event)
+}
+
+// markFinished moves the step to completed or failed state, and emits EventStepCompleted or EventStepFailed.
+func (si *StepInstance[T]) markFinished(err error) {
+	si.mutex.Lock()
+	si.executionData.Duration = time.Since(si.executionData.StartTime)
+	eventType := EventStepCompleted
+	si.state = StepStateCompleted
+	if err != nil {
+		eventType = EventStepFailed
+		si.state = StepStateFailed
+		si.err = err
+	}
+	event := si.newEvent(eventType, err)
+	si.mutex.Unlock()
+
+	si.JobInstance.emitEvent(event)
+}
+
+// markBlocked moves the step to blocked state with ErrPrecedentStepFailed, and emits EventStepBlocked.
+//
+//	precedentErr is the error of the failed precedent step.
+func (si *StepInstance[T]) markBlocked(precedentErr error) *JobError {
+	precedentStepName := ""
+	precedentJobErr := &JobError{}
+	if errors.As(precedentErr, &precedentJobErr) && precedentJobErr.StepInstance != nil {
+		precedentStepName = precedentJobErr.StepInstance.GetName()
+	}
+	stepErr := newStepError(ErrPrecedentStepFailed, si, precedentErr)
+	stepErr.Message = fmt.Sprintf(MsgPrecedentStepFailed, si.GetName(), precedentStepName)
+
+	si.mutex.Lock()
+	si.state = StepStateBlocked
+	si.err = stepErr
+	event := si.newEvent(EventStepBlocked, stepErr)
+	si.mutex.Unlock()
+
+	si.JobInstance.emitEvent(event)
+	return stepErr
+}
+
+// newEvent creates a event with snapshot of current execution data, caller should hold the lock.
+func (si *StepInstance[T]) newEvent(eventType JobEventType, err error) JobEvent {
+	return JobEvent{
+		Type:          eventType,
+		JobInstanceId: si.JobInstance.GetJobInstanceId(),
+		StepName:      si.GetName(),
+		Timestamp:     time.Now(),
+		ExecutionData: si.executionData.snapshot(),
+		Error:         err,
+	}
+}
+
+func (si *StepInstance[T]) snapshot() *StepInstanceSnapshot {
+	si.mutex.RLock()
+	defer si.mutex.RUnlock()
+
+	return newStepInstanceSnapshot(si.GetName(), si.Definition.DependsOn(), *si.executionData, si.state, si.err, si.restored)
+}
+
+// executionDataSnapshot returns a copy of execution data along with the state, consistent with each other.
+func (si *StepInstance[T]) executionDataSnapshot() (StepExecutionData, StepState) {
+	si.mutex.RLock()
+	defer si.mutex.RUnlock()
+	return si.executionData.snapshot(), si.state
 }
 
 func (si *StepInstance[T]) DotSpec() *graph.DotNodeSpec {
@@ -92,28 +296,35 @@ func (si *StepInstance[T]) DotSpec() *graph.DotNodeSpec {
 		shape = "triangle"
 	}
 
-	color := "gray"
-	switch si.state {
-	case StepStatePending:
-		color = "gray"
-	case StepStateRunning:
-		color = "yellow"
-	case StepStateCompleted:
-		color = "green"
-	case StepStateFailed:
-		color = "red"
-	}
+	si.mutex.RLock()
+	defer si.mutex.RUnlock()
+
+	color := si.state.Color()
 
+	style := "filled"
 	tooltip := ""
-	if si.state != StepStatePending && si.executionData != nil {
-		tooltip = fmt.Sprintf("State: %s\\nStartAt: %s\\nDuration: %s", si.state, si.executionData.StartTime.Format(time.RFC3339Nano), si.executionData.Duration)
+	if si.restored {
+		// restored steps didn't run in this job instance, no execution data to show.
+		style = "filled,dashed"
+		color = "lightblue"
+		tooltip = fmt.Sprintf("State: %s\nRestored from StateStore", si.state)
+	} else if si.state == StepStateBlocked {
+		// blocked steps never ran, dotted to tell from pending steps still waiting.
+		style = "filled,dotted"
+		tooltip = fmt.Sprintf("State: %s\nNever ran: %s", si.state, si.err)
+	} else if si.state != StepStatePending && si.executionData != nil {
+		tooltip = fmt.Sprintf("State: %s\nStartAt: %s\nDuration: %s", si.state, si.executionData.StartTime.Format(time.RFC3339Nano), si.executionData.Duration)
+		if si.err != nil {
+			// tooltip is escaped by graph renderers, error message is safe to show as is.
+			tooltip += fmt.Sprintf("\nError: %s", si.err)
+		}
 	}
 
 	return &graph.DotNodeSpec{
 		Name:        si.GetName(),
 		DisplayName: si.GetName(),
 		Shape:       shape,
-		Style:       "filled",
+		Style:       style,
 		FillColor:   color,
 		Tooltip:     tooltip,
 	}
@@ -128,16 +339,18 @@ func connectStepInstance(stepFrom, stepTo StepInstanceMeta) *graph.DotEdgeSpec {
 	}
 
 	// update edge color, tooltip if NodeTo is started already.
-	if stepTo.GetState() != StepStatePending {
-		executionData := stepTo.ExecutionData()
-		edgeSpec.Tooltip = fmt.Sprintf("Time: %s", executionData.StartTime.Format(time.RFC3339Nano))
+	if stepToSnapshot := snapshotStep(stepTo); stepToSnapshot.State != StepStatePending && stepToSnapshot.StartTime != nil {
+		edgeSpec.Tooltip = fmt.Sprintf("Time: %s", stepToSnapshot.StartTime.Format(time.RFC3339Nano))
 	}
 
 	fromNodeState := stepFrom.GetState()
 	if fromNodeState == StepStateCompleted {
 		edgeSpec.Color = "green"
-	} else if fromNodeState == StepStateFailed {
+	} else if fromNodeState == StepStateFailed || fromNodeState == StepStateBlocked {
 		edgeSpec.Color = "red"
+		if fromNodeState == StepStateBlocked {
+			edgeSpec.Style = "dotted"
+		}
 	}
 
 	return edgeSpec
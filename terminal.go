@@ -0,0 +1,130 @@
+package asyncjob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Azure/go-asyncjob/graph"
+)
+
+type TerminalOptions struct {
+	// Colors the steps by state with ANSI escape codes.
+	Colors bool
+	// Follow redraws the progress in place on every step state change, until the job instance finished.
+	Follow bool
+}
+
+type TerminalOptionPreparer func(*TerminalOptions) *TerminalOptions
+
+// WithTerminalColors colors the steps by state, with same color scheme as Visualize.
+func WithTerminalColors() TerminalOptionPreparer {
+	return func(options *TerminalOptions) *TerminalOptions {
+		options.Colors = true
+		return options
+	}
+}
+
+// WithTerminalFollow keeps redrawing the progress in place until the job instance finished, w should be a terminal.
+func WithTerminalFollow() TerminalOptionPreparer {
+	return func(options *TerminalOptions) *TerminalOptions {
+		options.Follow = true
+		return options
+	}
+}
+
+// RenderTerminal writes progress of the job instance as box-drawing text, steps are laid out by level with their state.
+//
+//	by default a single frame is written, which fits test logs.
+//	with WithTerminalFollow, it blocks and redraws on every step state change, until the job instance finished or ctx is done.
+//	failure to render or write a frame is returned, instead of writing a partial frame.
+func (ji *JobInstance[T]) RenderTerminal(ctx context.Context, w io.Writer, optionDecorators ...TerminalOptionPreparer) error {
+	options := &TerminalOptions{}
+	for _, decorator := range optionDecorators {
+		options = decorator(options)
+	}
+	renderer := graph.TerminalRenderer{Colors: options.Colors}
+
+	if !options.Follow {
+		frame, err := ji.terminalFrame(renderer)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, frame)
+		return err
+	}
+
+	subscription := ji.Subscribe(WithEventOverflowPolicy(EventOverflowDropOldest))
+	defer subscription.Unsubscribe()
+
+	previousLines := 0
+	for {
+		frame, err := ji.terminalFrame(renderer)
+		if err != nil {
+			return err
+		}
+		if previousLines > 0 {
+			// move cursor to the beginning of previous frame, and clear it.
+			frame = fmt.Sprintf("\x1b[%dA\x1b[J", previousLines) + frame
+		}
+		if _, err := io.WriteString(w, frame); err != nil {
+			return err
+		}
+		previousLines = strings.Count(frame, "\n")
+
+		select {
+		case _, ok := <-subscription.Events():
+			if !ok {
+				// job instance finished, draw the final state.
+				frame, err := ji.terminalFrame(renderer)
+				if err != nil {
+					return err
+				}
+				_, err = io.WriteString(w, fmt.Sprintf("\x1b[%dA\x1b[J", previousLines)+frame)
+				return err
+			}
+			// coalesce events arrived together into one redraw.
+			for drained := false; !drained; {
+				select {
+				case _, ok := <-subscription.Events():
+					drained = !ok
+				default:
+					drained = true
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// terminalFrame renders current state of the job instance, with state and retries in step labels.
+func (ji *JobInstance[T]) terminalFrame(renderer graph.TerminalRenderer) (string, error) {
+	ji.mutex.RLock()
+	renderGraph := ji.stepsDag.ToRenderGraph()
+	ji.mutex.RUnlock()
+
+	for _, node := range renderGraph.Nodes {
+		step, ok := ji.GetStepInstance(node.Name)
+		if !ok {
+			continue
+		}
+		stepSnapshot := snapshotStep(step)
+		label := fmt.Sprintf("%s [%s]", node.Name, stepSnapshot.State)
+		if stepSnapshot.Restored {
+			label = fmt.Sprintf("%s [restored]", node.Name)
+		}
+		if stepSnapshot.Retries > 0 {
+			label += fmt.Sprintf(" retries: %d", stepSnapshot.Retries)
+		}
+		node.Attributes["label"] = label
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%s (%s): %s\n", ji.Definition.GetName(), ji.GetJobInstanceId(), ji.GetState())
+	if err := renderer.Render(sb, renderGraph); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
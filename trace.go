@@ -0,0 +1,147 @@
+package asyncjob
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// traceEvent is an event in Chrome trace-event format.
+//
+//	https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type traceEvent struct {
+	Name string `json:"name"`
+	Cat  string `json:"cat,omitempty"`
+	Ph   string `json:"ph"`
+	// Ts and Dur are in microseconds, Ts is relative to job instance start.
+	Ts   float64        `json:"ts"`
+	Dur  float64        `json:"dur,omitempty"`
+	Pid  int            `json:"pid"`
+	Tid  int            `json:"tid"`
+	Id   int            `json:"id,omitempty"`
+	Bp   string         `json:"bp,omitempty"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+type traceFile struct {
+	TraceEvents     []*traceEvent `json:"traceEvents"`
+	DisplayTimeUnit string        `json:"displayTimeUnit"`
+}
+
+// traceStep is an executed step placed on a lane.
+type traceStep struct {
+	name      string
+	dependsOn []string
+	data      StepExecutionData
+	end       time.Time
+	lane      int
+}
+
+// ExportTrace writes the timeline of the job instance in Chrome trace-event JSON, open it in Perfetto or chrome://tracing.
+//
+//	steps running at same time are placed on separate lanes, retried attempts and backoff sleeps are nested in the step,
+//	dependencies are drawn as flow arrows. steps not executed (pending, restored from StateStore) are not included.
+//	running steps are exported up to now.
+func (ji *JobInstance[T]) ExportTrace(w io.Writer) error {
+	jobStartTime := ji.StartTime()
+	ts := func(t time.Time) float64 {
+		return float64(t.Sub(jobStartTime).Nanoseconds()) / 1e3
+	}
+	dur := func(d time.Duration) float64 {
+		return float64(d.Nanoseconds()) / 1e3
+	}
+
+	steps := make([]*traceStep, 0)
+	for _, step := range ji.getSteps() {
+		data, state := stepExecutionData(step)
+		if data.StartTime.IsZero() {
+			continue
+		}
+		executed := &traceStep{name: step.GetName(), dependsOn: step.GetStepDefinition().DependsOn(), data: data}
+		executed.end = data.StartTime.Add(data.Duration)
+		if state == StepStateRunning {
+			executed.end = time.Now()
+		}
+		steps = append(steps, executed)
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		if !steps[i].data.StartTime.Equal(steps[j].data.StartTime) {
+			return steps[i].data.StartTime.Before(steps[j].data.StartTime)
+		}
+		return steps[i].name < steps[j].name
+	})
+
+	// place each step on the first lane free at its start time.
+	var laneEnds []time.Time
+	stepsByName := make(map[string]*traceStep, len(steps))
+	for _, step := range steps {
+		step.lane = len(laneEnds)
+		for lane, laneEnd := range laneEnds {
+			if !laneEnd.After(step.data.StartTime) {
+				step.lane = lane
+				break
+			}
+		}
+		if step.lane == len(laneEnds) {
+			laneEnds = append(laneEnds, step.end)
+		} else {
+			laneEnds[step.lane] = step.end
+		}
+		stepsByName[step.name] = step
+	}
+
+	const pid = 1
+	events := []*traceEvent{
+		{Name: "process_name", Ph: "M", Pid: pid, Args: map[string]any{"name": fmt.Sprintf("%s (%s)", ji.Definition.GetName(), ji.GetJobInstanceId())}},
+	}
+	for lane := range laneEnds {
+		events = append(events, &traceEvent{Name: "thread_name", Ph: "M", Pid: pid, Tid: lane, Args: map[string]any{"name": fmt.Sprintf("lane %d", lane)}})
+	}
+
+	flowId := 0
+	for _, step := range steps {
+		stepEvent := &traceEvent{Name: step.name, Cat: "step", Ph: "X", Ts: ts(step.data.StartTime), Dur: dur(step.end.Sub(step.data.StartTime)), Pid: pid, Tid: step.lane}
+		if step.data.Retried != nil {
+			stepEvent.Args = map[string]any{"retries": step.data.Retried.Count}
+		}
+		events = append(events, stepEvent)
+
+		if step.data.Retried != nil && len(step.data.Retried.Attempts) > 0 {
+			lastAttemptStart := step.data.StartTime
+			for i, attempt := range step.data.Retried.Attempts {
+				attemptEvent := &traceEvent{Name: fmt.Sprintf("attempt %d", i+1), Cat: "attempt", Ph: "X", Ts: ts(attempt.StartTime), Dur: dur(attempt.Duration), Pid: pid, Tid: step.lane}
+				if attempt.Error != nil {
+					attemptEvent.Args = map[string]any{"error": attempt.Error.Error()}
+				}
+				backoffStart := attempt.StartTime.Add(attempt.Duration)
+				events = append(events,
+					attemptEvent,
+					&traceEvent{Name: "backoff", Cat: "backoff", Ph: "X", Ts: ts(backoffStart), Dur: dur(attempt.Backoff), Pid: pid, Tid: step.lane})
+				lastAttemptStart = backoffStart.Add(attempt.Backoff)
+			}
+			if lastAttemptStart.Before(step.end) {
+				events = append(events, &traceEvent{Name: fmt.Sprintf("attempt %d", len(step.data.Retried.Attempts)+1), Cat: "attempt", Ph: "X", Ts: ts(lastAttemptStart), Dur: dur(step.end.Sub(lastAttemptStart)), Pid: pid, Tid: step.lane})
+			}
+		}
+
+		for _, dependency := range step.dependsOn {
+			from, ok := stepsByName[dependency]
+			if !ok {
+				continue
+			}
+			// flow starts at the end of the dependency slice, still inside it, so the arrow binds to it.
+			flowStart := from.end.Add(-time.Microsecond)
+			if flowStart.Before(from.data.StartTime) {
+				flowStart = from.data.StartTime
+			}
+			flowId++
+			events = append(events,
+				&traceEvent{Name: "dependency", Cat: "dependency", Ph: "s", Ts: ts(flowStart), Pid: pid, Tid: from.lane, Id: flowId},
+				&traceEvent{Name: "dependency", Cat: "dependency", Ph: "f", Bp: "e", Ts: ts(step.data.StartTime), Pid: pid, Tid: step.lane, Id: flowId})
+		}
+	}
+
+	return json.NewEncoder(w).Encode(&traceFile{TraceEvents: events, DisplayTimeUnit: "ms"})
+}
@@ -0,0 +1,172 @@
+package asyncjob
+
+import (
+	"fmt"
+	"slices"
+)
+
+type ValidationWarningCode string
+
+const (
+	WarnStepNotReachingResult ValidationWarningCode = "StepNotReachingResult"
+	MsgStepNotReachingResult  string                = "step %q has no path to result step %q, its outcome doesn't affect the result"
+
+	WarnRedundantDependency ValidationWarningCode = "RedundantDependency"
+	MsgRedundantDependency  string                = "step %q executes after %q, which is already implied by depending on %q"
+
+	WarnUnusedStepOutput ValidationWarningCode = "UnusedStepOutput"
+	MsgUnusedStepOutput  string                = "output of step %q is never consumed by other steps"
+
+	WarnExplicitRootDependency ValidationWarningCode = "ExplicitRootDependency"
+	MsgExplicitRootDependency  string                = "step %q depends on root step %q explicitly, every step runs after it already"
+
+	WarnDuplicateDependency ValidationWarningCode = "DuplicateDependency"
+	MsgDuplicateDependency  string                = "step %q depends on %q more than once"
+)
+
+// ValidationWarning is a suspicious wiring found by Validate, the job definition still works as wired.
+type ValidationWarning struct {
+	Code     ValidationWarningCode
+	StepName string
+	Message  string
+}
+
+func (w ValidationWarning) String() string {
+	return string(w.Code) + ": " + w.Message
+}
+
+// Validate checks the job definition, meant to be called in unit tests to catch wiring mistakes before production.
+//
+//	error is returned if the steps graph is invalid (like cycles), warnings are returned for:
+//	redundant ExecuteAfter already implied transitively, same dependency listed more than once,
+//	outputs never consumed, DependsOn naming the root step.
+//	warnings are ordered by step execution order, it doesn't seal the definition.
+func (jd *JobDefinition[T]) Validate() ([]ValidationWarning, error) {
+	if err := jd.stepsDag.Validate(); err != nil {
+		return nil, ErrInvalidStepGraph.WithMessage(fmt.Sprintf(MsgInvalidStepGraph, jd.GetName(), err))
+	}
+
+	var warnings []ValidationWarning
+	consumed := make(map[string]bool)
+	for _, step := range jd.steps {
+		for _, inputStep := range step.getInputSteps() {
+			consumed[inputStep] = true
+		}
+	}
+
+	rootName := jd.rootStep.GetName()
+	for _, step := range jd.stepsDag.TopologicalSort() {
+		if step.GetName() == rootName {
+			continue
+		}
+
+		if !step.dependsOnRootImplicitly() && slices.Contains(step.DependsOn(), rootName) {
+			warnings = append(warnings, ValidationWarning{Code: WarnExplicitRootDependency, StepName: step.GetName(), Message: fmt.Sprintf(MsgExplicitRootDependency, step.GetName(), rootName)})
+		}
+
+		seen := make(map[string]bool, len(step.DependsOn()))
+		for _, dependency := range step.DependsOn() {
+			if seen[dependency] {
+				warnings = append(warnings, ValidationWarning{Code: WarnDuplicateDependency, StepName: step.GetName(), Message: fmt.Sprintf(MsgDuplicateDependency, step.GetName(), dependency)})
+			}
+			seen[dependency] = true
+		}
+
+		redundant, err := jd.redundantDependencies(step)
+		if err != nil {
+			return nil, err
+		}
+		for _, dependency := range redundant {
+			warnings = append(warnings, ValidationWarning{Code: WarnRedundantDependency, StepName: step.GetName(), Message: fmt.Sprintf(MsgRedundantDependency, step.GetName(), dependency[0], dependency[1])})
+		}
+
+		if !consumed[step.GetName()] {
+			warnings = append(warnings, ValidationWarning{Code: WarnUnusedStepOutput, StepName: step.GetName(), Message: fmt.Sprintf(MsgUnusedStepOutput, step.GetName())})
+		}
+	}
+
+	return warnings, nil
+}
+
+// redundantDependencies returns pairs of (dependency, implied by) for ExecuteAfter dependencies of the step,
+// which are already ancestors of another dependency. dependencies taking output as input are never redundant.
+func (jd *JobDefinition[T]) redundantDependencies(step StepDefinitionMeta) ([][2]string, error) {
+	rootName := jd.rootStep.GetName()
+	ancestorsOf := make(map[string]map[string]bool)
+	for _, dependency := range step.DependsOn() {
+		dependencyStep, ok := jd.steps[dependency]
+		if !ok {
+			return nil, ErrRefStepNotInJob.WithMessage(fmt.Sprintf(MsgRefStepNotInJob, dependency))
+		}
+		ancestors, err := jd.stepsDag.Ancestors(dependencyStep)
+		if err != nil {
+			return nil, err
+		}
+		ancestorsOf[dependency] = make(map[string]bool, len(ancestors))
+		for _, ancestor := range ancestors {
+			ancestorsOf[dependency][ancestor.GetName()] = true
+		}
+	}
+
+	var redundant [][2]string
+	for _, dependency := range uniqueStrings(step.DependsOn()) {
+		// root step dependency is reported as ExplicitRootDependency.
+		if dependency == rootName || slices.Contains(step.getInputSteps(), dependency) {
+			continue
+		}
+		for _, other := range step.DependsOn() {
+			if other != dependency && ancestorsOf[other][dependency] {
+				redundant = append(redundant, [2]string{dependency, other})
+				break
+			}
+		}
+	}
+	return redundant, nil
+}
+
+func uniqueStrings(values []string) []string {
+	var unique []string
+	for _, value := range values {
+		if !slices.Contains(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// Validate checks the job definition same as JobDefinition.Validate, and warns steps with no path to the result step.
+//
+//	output of result step is consumed by the caller, so it is not warned as never consumed.
+func (jd *JobDefinitionWithResult[Tin, Tout]) Validate() ([]ValidationWarning, error) {
+	warnings, err := jd.JobDefinition.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors, err := jd.stepsDag.Ancestors(jd.resultStep)
+	if err != nil {
+		return nil, err
+	}
+	reachingResult := make(map[string]bool, len(ancestors))
+	for _, ancestor := range ancestors {
+		reachingResult[ancestor.GetName()] = true
+	}
+
+	resultName := jd.resultStep.GetName()
+	filtered := make([]ValidationWarning, 0, len(warnings))
+	for _, warning := range warnings {
+		if warning.Code == WarnUnusedStepOutput && warning.StepName == resultName {
+			continue
+		}
+		filtered = append(filtered, warning)
+	}
+
+	for _, step := range jd.stepsDag.TopologicalSort() {
+		if step.GetName() == resultName || reachingResult[step.GetName()] {
+			continue
+		}
+		filtered = append(filtered, ValidationWarning{Code: WarnStepNotReachingResult, StepName: step.GetName(), Message: fmt.Sprintf(MsgStepNotReachingResult, step.GetName(), resultName)})
+	}
+
+	return filtered, nil
+}
@@ -0,0 +1,37 @@
+package asyncjob
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/go-asyncjob/graph"
+)
+
+// VisualizeFormat is the output format of VisualizeAs.
+type VisualizeFormat string
+
+const (
+	// VisualizeFormatDOT is graphviz dot format, same as Visualize.
+	VisualizeFormatDOT VisualizeFormat = "dot"
+	// VisualizeFormatMermaid is Mermaid flowchart, rendered natively by GitHub markdown.
+	VisualizeFormatMermaid VisualizeFormat = "mermaid"
+	// VisualizeFormatJSON is the nodes and edges with their attributes, see graph.RenderGraph.
+	VisualizeFormatJSON VisualizeFormat = "json"
+)
+
+func visualizeAs[NT graph.NodeConstrain](g *graph.Graph[NT], format VisualizeFormat) (string, error) {
+	switch format {
+	case VisualizeFormatDOT:
+		return g.ToDotGraph()
+	case VisualizeFormatMermaid:
+		return g.ToMermaid()
+	case VisualizeFormatJSON:
+		buf := &strings.Builder{}
+		if err := g.Render(buf, graph.JSONRenderer{}); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	default:
+		return "", ErrUnsupportedVisualizeFormat.WithMessage(fmt.Sprintf(MsgUnsupportedVisualizeFormat, format))
+	}
+}